@@ -0,0 +1,57 @@
+package main
+
+import (
+	"time"
+
+	dispremote "github.com/leo82309/ipod/lingo-dispremote"
+	simpleremote "github.com/leo82309/ipod/lingo-simpleremote"
+	"github.com/leo82309/ipod/mpd"
+)
+
+// Config holds the settings of the player backends the accessory talks to.
+type Config struct {
+	MPDAddr      string
+	MPDPassword  string
+	PollInterval time.Duration
+	Artwork      bool // Fetch cover art for accessories with a screen
+	VolumeStep   int  // Volume change per press of a volume button
+	QueueSize    int  // Button presses kept while MPD is unreachable, 0 to fail them
+}
+
+var defaultConfig = Config{
+	MPDAddr:      mpd.DefaultAddr,
+	PollInterval: 1 * time.Second,
+	Artwork:      true,
+	VolumeStep:   5,
+}
+
+// MPDOptions returns the client options for connecting to MPD.
+func (c Config) MPDOptions() []mpd.Option {
+	var opts []mpd.Option
+	if c.MPDPassword != "" {
+		opts = append(opts, mpd.WithPassword(c.MPDPassword))
+	}
+	return opts
+}
+
+// WatchConfig returns the settings for watching the MPD server.
+func (c Config) WatchConfig() mpd.WatchConfig {
+	cfg := mpd.WatchConfig{
+		Addr:         c.MPDAddr,
+		Options:      c.MPDOptions(),
+		PollInterval: c.PollInterval,
+	}
+	if c.Artwork {
+		cfg.OnSongChange = dispremote.ArtworkUpdater(c.MPDAddr, c.MPDOptions()...)
+	}
+	return cfg
+}
+
+// Apply passes the configuration down to the lingo handlers.
+func (c Config) Apply() {
+	simpleremote.Configure(c.MPDAddr, c.MPDOptions()...)
+	if c.VolumeStep > 0 {
+		simpleremote.VolumeStep = c.VolumeStep
+	}
+	simpleremote.QueueSize = c.QueueSize
+}
@@ -81,6 +81,11 @@ func main() {
 			Name:  "debug, d",
 			Usage: "verbose logging",
 		},
+		cli.StringFlag{
+			Name:  "log-level",
+			Usage: "log `level` (debug, info, warn, error)",
+			Value: "info",
+		},
 		cli.BoolFlag{
 			Name:  "legacy, l",
 			Usage: "use legacy hid descriptor",
@@ -100,6 +105,11 @@ func main() {
 	}
 
 	app.Before = func(c *cli.Context) error {
+		level, err := logrus.ParseLevel(c.GlobalString("log-level"))
+		if err != nil {
+			return UsageError{err}
+		}
+		log.SetLevel(level)
 		if c.GlobalBool("debug") {
 			log.SetLevel(logrus.DebugLevel)
 		}
@@ -131,6 +141,33 @@ func main() {
 					Name:  "write-trace, w",
 					Usage: "Write trace to a `file`",
 				},
+				cli.StringFlag{
+					Name:  "mpd-addr",
+					Usage: "MPD server `address` (host:port or unix socket path)",
+					Value: defaultConfig.MPDAddr,
+				},
+				cli.StringFlag{
+					Name:  "mpd-password",
+					Usage: "MPD server `password`",
+				},
+				cli.DurationFlag{
+					Name:  "poll-interval",
+					Usage: "MPD status poll `interval`",
+					Value: defaultConfig.PollInterval,
+				},
+				cli.IntFlag{
+					Name:  "volume-step",
+					Usage: "volume change per press of a volume button",
+					Value: defaultConfig.VolumeStep,
+				},
+				cli.IntFlag{
+					Name:  "queue-commands",
+					Usage: "keep up to `n` button presses while MPD is unreachable and send them once it is back",
+				},
+				cli.BoolFlag{
+					Name:  "no-artwork",
+					Usage: "do not fetch cover art for accessory screens",
+				},
 			},
 			Action: func(c *cli.Context) error {
 				path := c.Args().First()
@@ -159,8 +196,20 @@ func main() {
 
 				reportR, reportW := hid.NewReportReader(rw), hid.NewReportWriter(rw)
 				frameTransport := hid.NewTransport(reportR, reportW, hidReportDefs)
-				go mpd.WatchStatus("127.0.0.1:6600", 1*time.Second)
+				cfg := Config{
+					MPDAddr:      c.String("mpd-addr"),
+					MPDPassword:  c.String("mpd-password"),
+					PollInterval: c.Duration("poll-interval"),
+					Artwork:      !c.Bool("no-artwork"),
+					VolumeStep:   c.Int("volume-step"),
+					QueueSize:    c.Int("queue-commands"),
+				}
+				cfg.Apply()
+				go mpd.WatchStatusConfig(cfg.WatchConfig())
 				processFrames(frameTransport)
+				if err := simpleremote.Shutdown(); err != nil {
+					log.WithError(err).Warn("could not close the mpd connection")
+				}
 				return nil
 			},
 		},
@@ -281,8 +330,6 @@ func main() {
 				}
 
 				select {}
-
-				return nil
 			},
 		},
 	}
@@ -385,6 +432,7 @@ func processFrames(frameTransport ipod.FrameReadWriter) {
 }
 
 var devGeneral = &DevGeneral{}
+var devSimpleRemote = &DevSimpleRemote{}
 
 func handlePacket(cmdWriter ipod.CommandWriter, cmd *ipod.Command) {
 	switch cmd.ID.LingoID() {
@@ -397,7 +445,7 @@ func handlePacket(cmdWriter ipod.CommandWriter, cmd *ipod.Command) {
 		general.HandleGeneral(cmd, cmdWriter, devGeneral)
 
 	case ipod.LingoSimpleRemoteID:
-		simpleremote.HandleSimpleRemote(cmd, cmdWriter, nil)
+		simpleremote.HandleSimpleRemote(cmd, cmdWriter, devSimpleRemote)
 	case ipod.LingoDisplayRemoteID:
 		dispremote.HandleDispRemote(cmd, cmdWriter, nil)
 	case ipod.LingoExtRemoteID:
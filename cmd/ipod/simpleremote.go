@@ -0,0 +1,17 @@
+package main
+
+import (
+	simpleremote "github.com/leo82309/ipod/lingo-simpleremote"
+)
+
+type DevSimpleRemote struct{}
+
+var _ simpleremote.DeviceSimpleRemote = &DevSimpleRemote{}
+
+func (d *DevSimpleRemote) Name() string {
+	return "ipod-gadget"
+}
+
+func (d *DevSimpleRemote) VolumeStep() int {
+	return 0 // Use the --volume-step setting
+}
@@ -0,0 +1,217 @@
+package dispremote
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // Decoders for the usual cover formats
+	_ "image/png"
+	"sync"
+
+	"github.com/leo82309/ipod"
+	"github.com/leo82309/ipod/mpd"
+	"github.com/sirupsen/logrus"
+)
+
+// ArtworkSize is the largest width and height of the artwork sent to the
+// accessory. Larger covers are scaled down to fit, keeping their aspect
+// ratio.
+var ArtworkSize = 128
+
+const (
+	artworkFormatID     = 0
+	pixelFormatRGB565LE = 0x02
+
+	// artworkChunkSize is the number of pixel bytes per RetTrackArtworkData
+	// packet.
+	artworkChunkSize = 500
+
+	// maxArtworkPixels is the largest cover, in pixels, that is decoded.
+	maxArtworkPixels = 4096 * 4096
+)
+
+// artwork is a cover converted to the pixel format sent to the accessory.
+type artwork struct {
+	songID        int // Song the cover belongs to
+	width, height int
+	pixels        []byte // RGB565, little endian, row by row
+}
+
+var (
+	artworkMutex   sync.Mutex
+	currentArtwork *artwork
+)
+
+func setArtwork(a *artwork) {
+	artworkMutex.Lock()
+	currentArtwork = a
+	artworkMutex.Unlock()
+}
+
+func getArtwork() *artwork {
+	artworkMutex.Lock()
+	defer artworkMutex.Unlock()
+	return currentArtwork
+}
+
+// artworkFormats lists the format the artwork is offered in.
+func artworkFormats() []ArtworkFormat {
+	return []ArtworkFormat{{
+		FormatID:    artworkFormatID,
+		PixelFormat: pixelFormatRGB565LE,
+		ImageWidth:  uint16(ArtworkSize),
+		ImageHeight: uint16(ArtworkSize),
+	}}
+}
+
+// UpdateArtwork fetches the cover of the song with the given id in the
+// queue, embedded picture first and the cover file of its directory second,
+// and keeps it scaled to ArtworkSize for GetTrackArtworkData. The artwork is
+// cleared if the song has none.
+func UpdateArtwork(client *mpd.Client, songID int) error {
+	item, err := client.PlaylistID(songID)
+	if err != nil {
+		setArtwork(nil)
+		return err
+	}
+	a, err := loadArtwork(client, item.File)
+	if a != nil {
+		a.songID = songID
+	}
+	setArtwork(a)
+	return err
+}
+
+// loadArtwork fetches and converts the cover of the song file. It returns
+// nil if the song has none.
+func loadArtwork(client *mpd.Client, file string) (*artwork, error) {
+	data, err := fetchCover(client, file)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	// Check the dimensions before decoding, as a small file can declare a
+	// huge image.
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxArtworkPixels {
+		return nil, fmt.Errorf("cover of %s is %dx%d pixels, more than the limit of %d", file, cfg.Width, cfg.Height, maxArtworkPixels)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return newArtwork(img, ArtworkSize), nil
+}
+
+// fetchCover returns the raw cover image of file, or nil if there is none.
+func fetchCover(client *mpd.Client, file string) ([]byte, error) {
+	data, _, err := client.ReadPicture(file)
+	if err != nil && !errors.Is(err, mpd.ErrUnsupported) && !errors.Is(err, mpd.ErrNoExist) {
+		return nil, err
+	}
+	if data != nil {
+		return data, nil
+	}
+	data, err = client.AlbumArt(file)
+	if errors.Is(err, mpd.ErrUnsupported) || errors.Is(err, mpd.ErrNoExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// newArtwork scales img down to fit in size x size pixels and converts it
+// to RGB565.
+func newArtwork(img image.Image, size int) *artwork {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, h*size/w
+		} else {
+			w, h = w*size/h, size
+		}
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	a := &artwork{width: w, height: h, pixels: make([]byte, 0, w*h*2)}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			// Nearest neighbour is good enough for the small screens.
+			r, g, bl, _ := img.At(b.Min.X+x*b.Dx()/w, b.Min.Y+y*b.Dy()/h).RGBA()
+			p := uint16(r>>11)<<11 | uint16(g>>10)<<5 | uint16(bl>>11)
+			a.pixels = append(a.pixels, byte(p), byte(p>>8))
+		}
+	}
+	return a
+}
+
+// sendArtwork responds to req with the artwork, split into as many
+// RetTrackArtworkData packets as needed.
+func sendArtwork(req *ipod.Command, tr ipod.CommandWriter, a *artwork) {
+	data := a.pixels
+	for i := 0; i == 0 || len(data) > 0; i++ {
+		n := artworkChunkSize
+		if n > len(data) {
+			n = len(data)
+		}
+		pkt := &RetTrackArtworkData{PacketIndex: uint16(i), Data: data[:n]}
+		if i == 0 {
+			pkt.PixelFormat = pixelFormatRGB565LE
+			pkt.ImageWidth = uint16(a.width)
+			pkt.ImageHeight = uint16(a.height)
+			pkt.BottomRightX = uint16(a.width - 1)
+			pkt.BottomRightY = uint16(a.height - 1)
+			pkt.RowSize = uint32(a.width * 2)
+		}
+		ipod.Respond(req, tr, pkt)
+		data = data[n:]
+	}
+}
+
+// ArtworkUpdater returns a song change hook for mpd.WatchConfig that
+// fetches the new song's artwork from the MPD server at addr, so that it is
+// fetched once per song rather than on every request. The artwork is
+// fetched in the background so that the watcher isn't held up, and only for
+// the latest song if several changes arrive during a fetch.
+func ArtworkUpdater(addr string, opts ...mpd.Option) func(*mpd.SongChangedEvent) {
+	songs := make(chan int, 1)
+	go func() {
+		for songID := range songs {
+			if songID < 0 {
+				setArtwork(nil)
+				continue
+			}
+			if err := updateArtworkForSong(addr, opts, songID); err != nil {
+				logrus.WithError(err).Warn("dispremote: could not update artwork")
+			}
+		}
+	}()
+	return func(ev *mpd.SongChangedEvent) {
+		// The old cover no longer matches; requests are refused until the
+		// new one is in.
+		setArtwork(nil)
+		// Replace a song whose fetch hasn't started yet.
+		select {
+		case <-songs:
+		default:
+		}
+		songs <- ev.NewSongID
+	}
+}
+
+func updateArtworkForSong(addr string, opts []mpd.Option, songID int) error {
+	client, err := mpd.NewClient(addr, opts...)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return UpdateArtwork(client, songID)
+}
@@ -51,8 +51,9 @@ var Lingos struct {
 type ACKStatus uint8
 
 const (
-	ACKStatusSuccess ACKStatus = 0x00
-	ACKStatusPending ACKStatus = 0x06
+	ACKStatusSuccess      ACKStatus = 0x00
+	ACKStatusBadParameter ACKStatus = 0x04
+	ACKStatusPending      ACKStatus = 0x06
 )
 
 type ACK struct {
@@ -388,14 +389,89 @@ type ArtworkFormat struct {
 type RetArtworkFormats struct {
 	Formats []ArtworkFormat
 }
+
+func (r *RetArtworkFormats) MarshalBinary() ([]byte, error) {
+	buf := bytes.Buffer{}
+	err := binary.Write(&buf, binary.BigEndian, r.Formats)
+	return buf.Bytes(), err
+}
+
+func (r *RetArtworkFormats) UnmarshalBinary(data []byte) error {
+	r.Formats = make([]ArtworkFormat, len(data)/binary.Size(ArtworkFormat{}))
+	return binary.Read(bytes.NewReader(data), binary.BigEndian, r.Formats)
+}
+
 type GetTrackArtworkData struct {
 	TrackIndex uint32
 	FormatID   uint16
 	TimeOffset uint32
 }
+
+// RetTrackArtworkData carries one packet of artwork pixels. The image
+// description fields are only present in the first packet (index 0).
 type RetTrackArtworkData struct {
-	//todo
+	PacketIndex  uint16
+	PixelFormat  uint8
+	ImageWidth   uint16
+	ImageHeight  uint16
+	TopLeftX     uint16
+	TopLeftY     uint16
+	BottomRightX uint16
+	BottomRightY uint16
+	RowSize      uint32
+	Data         []byte
+}
+
+type artworkDataHeader struct {
+	PixelFormat  uint8
+	ImageWidth   uint16
+	ImageHeight  uint16
+	TopLeftX     uint16
+	TopLeftY     uint16
+	BottomRightX uint16
+	BottomRightY uint16
+	RowSize      uint32
+}
+
+func (r *RetTrackArtworkData) MarshalBinary() ([]byte, error) {
+	buf := bytes.Buffer{}
+	binary.Write(&buf, binary.BigEndian, r.PacketIndex)
+	if r.PacketIndex == 0 {
+		binary.Write(&buf, binary.BigEndian, artworkDataHeader{
+			PixelFormat:  r.PixelFormat,
+			ImageWidth:   r.ImageWidth,
+			ImageHeight:  r.ImageHeight,
+			TopLeftX:     r.TopLeftX,
+			TopLeftY:     r.TopLeftY,
+			BottomRightX: r.BottomRightX,
+			BottomRightY: r.BottomRightY,
+			RowSize:      r.RowSize,
+		})
+	}
+	buf.Write(r.Data)
+	return buf.Bytes(), nil
+}
+
+func (r *RetTrackArtworkData) UnmarshalBinary(data []byte) error {
+	rd := bytes.NewReader(data)
+	if err := binary.Read(rd, binary.BigEndian, &r.PacketIndex); err != nil {
+		return err
+	}
+	if r.PacketIndex == 0 {
+		var h artworkDataHeader
+		if err := binary.Read(rd, binary.BigEndian, &h); err != nil {
+			return err
+		}
+		r.PixelFormat, r.ImageWidth, r.ImageHeight = h.PixelFormat, h.ImageWidth, h.ImageHeight
+		r.TopLeftX, r.TopLeftY = h.TopLeftX, h.TopLeftY
+		r.BottomRightX, r.BottomRightY = h.BottomRightX, h.BottomRightY
+		r.RowSize = h.RowSize
+	}
+	r.Data = make([]byte, rd.Len())
+	rd.Read(r.Data)
+	return nil
 }
+
 type GetPowerBatteryState struct {
 }
 type RetPowerBatteryState struct {
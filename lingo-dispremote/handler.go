@@ -173,10 +173,15 @@ func HandleDispRemote(req *ipod.Command, tr ipod.CommandWriter, dev DeviceDispRe
 			NumPlayTracks: uint32(mpd.CurrentStatus.PlaylistLength),
 		})
 	case *GetArtworkFormats:
-		ipod.Respond(req, tr, &RetArtworkFormats{})
+		ipod.Respond(req, tr, &RetArtworkFormats{Formats: artworkFormats()})
 	case *GetTrackArtworkData:
-	// RetTrackArtworkData:
-	//todo
+		a := getArtwork()
+		st := mpd.LatestStatus()
+		if a == nil || st == nil || a.songID != st.SongID || msg.FormatID != artworkFormatID || msg.TrackIndex != uint32(st.Song) {
+			ipod.Respond(req, tr, &ACK{Status: ACKStatusBadParameter, CmdID: uint8(req.ID.CmdID())})
+			break
+		}
+		sendArtwork(req, tr, a)
 	case *GetPowerBatteryState:
 		ipod.Respond(req, tr, &RetPowerBatteryState{
 			BatteryLevel: 255, // 100%
@@ -852,6 +852,20 @@ type AccessoryStatusNotification struct {
 	StatusParams []byte
 }
 
+func (s AccessoryStatusNotification) MarshalBinary() ([]byte, error) {
+	return append([]byte{s.StatusType}, s.StatusParams...), nil
+}
+
+func (s *AccessoryStatusNotification) UnmarshalBinary(data []byte) error {
+	if len(data) < 1 {
+		return errors.New("short packet")
+	}
+	s.StatusType = data[0]
+	s.StatusParams = make([]byte, len(data)-1)
+	copy(s.StatusParams, data[1:])
+	return nil
+}
+
 type SetEventNotification struct {
 	EventMask uint64
 }
@@ -1,26 +1,55 @@
 package simpleremote
 
 import (
-	"log"
+	"errors"
+	"net"
 	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
 
 	"github.com/leo82309/ipod"
 	"github.com/leo82309/ipod/mpd"
 )
 
+var log logrus.FieldLogger = logrus.StandardLogger()
+
+// SetLogger sets the logger used by the package. By default the logrus
+// standard logger is used, so its level also applies here.
+func SetLogger(l logrus.FieldLogger) {
+	log = l
+}
+
 type DeviceSimpleRemote interface {
+	// Name identifies the device in logs.
+	Name() string
+	// VolumeStep is the volume change per press of a volume button, or 0
+	// to use the package-wide VolumeStep.
+	VolumeStep() int
 }
 
 var (
-	mpdClient *mpd.Client
-	mpdMutex  sync.Mutex
+	mpdClient  *mpd.Client
+	mpdMutex   sync.Mutex
+	cmdMutex   sync.Mutex
+	mpdAddr    = mpd.DefaultAddr
+	mpdOptions []mpd.Option
 )
 
+// Configure sets the MPD server the remote buttons control.
+// It takes effect the next time a connection is made.
+func Configure(addr string, opts ...mpd.Option) {
+	mpdMutex.Lock()
+	defer mpdMutex.Unlock()
+	mpdAddr = addr
+	mpdOptions = opts
+}
+
 func getMpdClient() (*mpd.Client, error) {
 	mpdMutex.Lock()
 	defer mpdMutex.Unlock()
 	if mpdClient == nil {
-		client, err := mpd.NewClient("127.0.0.1:6600")
+		client, err := mpd.NewClient(mpdAddr, mpdOptions...)
 		if err != nil {
 			return nil, err
 		}
@@ -29,24 +58,246 @@ func getMpdClient() (*mpd.Client, error) {
 	return mpdClient, nil
 }
 
+// Shutdown closes the shared MPD connection. A new one is made the next
+// time a button is pressed.
+func Shutdown() error {
+	mpdMutex.Lock()
+	defer mpdMutex.Unlock()
+	if mpdClient == nil {
+		return nil
+	}
+	err := mpdClient.Close()
+	mpdClient = nil
+	return err
+}
+
+// resetMpdClient drops the shared client if it is still client, so that the
+// next call to getMpdClient reconnects.
+func resetMpdClient(client *mpd.Client) {
+	mpdMutex.Lock()
+	defer mpdMutex.Unlock()
+	if mpdClient == client {
+		mpdClient.Close()
+		mpdClient = nil
+	}
+}
+
+// isConnError reports whether err means the connection to MPD is broken,
+// as opposed to MPD rejecting the command.
+func isConnError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, mpd.ErrConnectionLost) || errors.As(err, &netErr)
+}
+
+// withMpdClient calls fn with the shared client. If fn fails because the
+// connection is broken (e.g. MPD was restarted), the client is reconnected
+// and fn is retried once.
+func withMpdClient(fn func(*mpd.Client) error) error {
+	// The client is shared with the scrubbing goroutine but can only run
+	// one command at a time.
+	cmdMutex.Lock()
+	defer cmdMutex.Unlock()
+
+	client, err := getMpdClient()
+	if err != nil {
+		return err
+	}
+	err = fn(client)
+	if err == nil || !isConnError(err) {
+		return err
+	}
+
+	log.WithError(err).Info("SimpleRemote: mpd connection lost, reconnecting")
+	resetMpdClient(client)
+	// The status watcher has most likely lost its connection too.
+	mpd.RequestReconnect()
+	client, err = getMpdClient()
+	if err != nil {
+		return err
+	}
+	return fn(client)
+}
+
+// VolumeStep is the volume change per press of a volume button for devices
+// that don't specify their own.
+var VolumeStep = 5
+
+// volumeStep returns the volume step of dev.
+func volumeStep(dev DeviceSimpleRemote) int {
+	if dev != nil {
+		if step := dev.VolumeStep(); step > 0 {
+			return step
+		}
+	}
+	return VolumeStep
+}
+
+// changeVolume changes the volume by delta, clamped to 0-100. It does
+// nothing if MPD has no mixer to control.
+func changeVolume(client *mpd.Client, delta int) error {
+	s, err := client.Status()
+	if err != nil {
+		return err
+	}
+	if !s.VolumeAvailable {
+		log.Debug("SimpleRemote: volume is not available, ignoring")
+		return nil
+	}
+	volume := s.Volume + delta
+	if volume < 0 {
+		volume = 0
+	} else if volume > 100 {
+		volume = 100
+	}
+	return client.SetVolume(volume)
+}
+
+// RepeatWindow is the time during which repeated frames with the same
+// button state are treated as a single press. Some docks send a burst of
+// frames while a button is held, which would otherwise skip several tracks
+// at once. Zero disables coalescing.
+var RepeatWindow = 300 * time.Millisecond
+
+var (
+	lastButtonMutex sync.Mutex
+	lastButtonState ContextButtonMask
+	lastButtonTime  time.Time
+)
+
+// isRepeat reports whether state repeats the last handled button state
+// within RepeatWindow. Otherwise state is recorded as the last one. A
+// release (zero state) forgets the last state, so a quick press, release
+// and press of the same button counts twice.
+func isRepeat(state ContextButtonMask, now time.Time) bool {
+	lastButtonMutex.Lock()
+	defer lastButtonMutex.Unlock()
+	if state == 0 {
+		lastButtonState, lastButtonTime = 0, time.Time{}
+		return false
+	}
+	if state == lastButtonState && now.Sub(lastButtonTime) < RepeatWindow {
+		return true
+	}
+	lastButtonState = state
+	lastButtonTime = now
+	return false
+}
+
+var (
+	// ScrubInterval is how often the position is moved while the fast
+	// forward or rewind button is held.
+	ScrubInterval = 200 * time.Millisecond
+	// ScrubStep is the initial number of seconds moved per ScrubInterval.
+	// It doubles every second the button is held, up to ScrubMaxStep.
+	ScrubStep    = 2.0
+	ScrubMaxStep = 16.0
+)
+
+var (
+	scrubMutex sync.Mutex
+	scrubDir   int
+	scrubStop  chan struct{}
+)
+
+// updateScrub starts or stops seeking according to the fast forward and
+// rewind bits of state. Seeking continues until a frame without the bit
+// (usually the release frame) arrives.
+func updateScrub(state ContextButtonMask) {
+	dir := 0
+	switch {
+	case state&ContextButtonMask(ContextButtonBeginFastForward) != 0:
+		dir = 1
+	case state&ContextButtonMask(ContextButtonBeginRewind) != 0:
+		dir = -1
+	}
+
+	scrubMutex.Lock()
+	defer scrubMutex.Unlock()
+	if dir == scrubDir {
+		return
+	}
+	if scrubStop != nil {
+		close(scrubStop)
+		scrubStop = nil
+	}
+	scrubDir = dir
+	if dir != 0 {
+		scrubStop = make(chan struct{})
+		go scrub(dir, scrubStop)
+	}
+}
+
+// scrub seeks in direction dir every ScrubInterval until stop is closed.
+func scrub(dir int, stop <-chan struct{}) {
+	ticker := time.NewTicker(ScrubInterval)
+	defer ticker.Stop()
+
+	step := ScrubStep
+	accelerateAt := time.Now().Add(time.Second)
+	for {
+		err := withMpdClient(func(client *mpd.Client) error {
+			return client.SeekCurRelative(float64(dir) * step)
+		})
+		if err != nil {
+			log.WithError(err).Warn("SimpleRemote: seek failed")
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		if now := time.Now(); now.After(accelerateAt) {
+			accelerateAt = now.Add(time.Second)
+			if step *= 2; step > ScrubMaxStep {
+				step = ScrubMaxStep
+			}
+		}
+	}
+}
+
 func HandleSimpleRemote(req *ipod.Command, tr ipod.CommandWriter, dev DeviceSimpleRemote) error {
 	switch msg := req.Payload.(type) {
 	case *ContextButtonStatus:
-		log.Printf("SimpleRemote: received %s", msg.State.String())
-		client, err := getMpdClient()
+		le := log
+		if dev != nil {
+			le = log.WithField("device", dev.Name())
+		}
+		le.Debugf("SimpleRemote: received %s", msg.State.String())
+		updateScrub(msg.State)
+		if isRepeat(msg.State, time.Now()) || msg.State == 0 {
+			return nil
+		}
+		err := withQueuedMpdClient(func(client *mpd.Client) error {
+			switch {
+			case msg.State&ContextButtonMask(ContextButtonPlayPause) != 0:
+				return client.PlayPause()
+			case msg.State&ContextButtonMask(ContextButtonVolumeUp) != 0:
+				return changeVolume(client, volumeStep(dev))
+			case msg.State&ContextButtonMask(ContextButtonVolumeDown) != 0:
+				return changeVolume(client, -volumeStep(dev))
+			case msg.State&ContextButtonMask(ContextButtonNextTrack) != 0:
+				return client.Next()
+			case msg.State&ContextButtonMask(ContextButtonPreviousTrack) != 0:
+				return client.Previous()
+			}
+			return nil
+		})
+		if errors.Is(err, mpd.ErrEmptyQueue) {
+			le.Info("SimpleRemote: queue is empty, nothing to play")
+			return nil
+		}
+		if errors.Is(err, mpd.ErrNoExist) {
+			// The song was removed from the queue since the status was read.
+			le.WithError(err).Info("SimpleRemote: song no longer exists")
+			return nil
+		}
 		if err != nil {
-			log.Panic("could not get mpd client")
+			le.WithError(err).Errorf("SimpleRemote: %s failed", msg.State.String())
 			return err
 		}
-
-		switch {
-		case msg.State&ContextButtonMask(ContextButtonPlayPause) != 0:
-			client.Pause(mpd.CurrentStatus.State == "play")
-		case msg.State&ContextButtonMask(ContextButtonNextTrack) != 0:
-			client.Next()
-		case msg.State&ContextButtonMask(ContextButtonPreviousTrack) != 0:
-			client.Previous()
-		}
 	default:
 		_ = msg
 	}
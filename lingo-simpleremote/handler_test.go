@@ -0,0 +1,171 @@
+package simpleremote
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leo82309/ipod/mpd"
+)
+
+func TestIsRepeat(t *testing.T) {
+	lastButtonState, lastButtonTime = 0, time.Time{}
+	start := time.Now()
+	next := ContextButtonMask(ContextButtonNextTrack)
+	prev := ContextButtonMask(ContextButtonPreviousTrack)
+
+	tests := []struct {
+		name  string
+		state ContextButtonMask
+		after time.Duration
+		want  bool
+	}{
+		{"first press", next, 0, false},
+		{"burst", next, 50 * time.Millisecond, true},
+		{"still held", next, 200 * time.Millisecond, true},
+		{"other button", prev, 250 * time.Millisecond, false},
+		{"back to next", next, 260 * time.Millisecond, false},
+		{"after window", next, 600 * time.Millisecond, false},
+		{"release", 0, 650 * time.Millisecond, false},
+		{"pressed again", next, 700 * time.Millisecond, false},
+		{"held again", next, 750 * time.Millisecond, true},
+	}
+	for _, tt := range tests {
+		if got := isRepeat(tt.state, start.Add(tt.after)); got != tt.want {
+			t.Errorf("%s: isRepeat() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+type testDevice int
+
+func (d testDevice) Name() string    { return "test" }
+func (d testDevice) VolumeStep() int { return int(d) }
+
+func TestVolumeStep(t *testing.T) {
+	defer func(old int) { VolumeStep = old }(VolumeStep)
+	VolumeStep = 3
+
+	if got := volumeStep(nil); got != 3 {
+		t.Errorf("volumeStep(nil) = %d, want 3", got)
+	}
+	if got := volumeStep(testDevice(0)); got != 3 {
+		t.Errorf("volumeStep(0) = %d, want the package default 3", got)
+	}
+	if got := volumeStep(testDevice(10)); got != 10 {
+		t.Errorf("volumeStep(10) = %d, want 10", got)
+	}
+}
+
+func TestCommandQueue(t *testing.T) {
+	defer func(size int, age time.Duration) { QueueSize, QueueMaxAge = size, age }(QueueSize, QueueMaxAge)
+	QueueSize, QueueMaxAge = 2, time.Second
+	defer func() { queue = nil }()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		pushCommand(queuedCommand{at: start.Add(time.Duration(i) * time.Second)})
+	}
+	if len(queue) != 2 || !queue[0].at.Equal(start.Add(time.Second)) {
+		t.Fatalf("queue = %v, want the two newest commands", queue)
+	}
+
+	pending := dropExpired(queue, start.Add(2500*time.Millisecond))
+	if len(pending) != 1 || !pending[0].at.Equal(start.Add(2*time.Second)) {
+		t.Errorf("dropExpired() = %v, want the newest command", pending)
+	}
+}
+
+// serveMPD answers every command on ln with OK and records the pause
+// commands it gets.
+func serveMPD(ln net.Listener, mu *sync.Mutex, got *[]string) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			fmt.Fprintf(conn, "OK MPD 0.23.5\n")
+			r := bufio.NewReader(conn)
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if cmd := strings.TrimSpace(line); strings.HasPrefix(cmd, "pause") {
+					mu.Lock()
+					*got = append(*got, cmd)
+					mu.Unlock()
+				}
+				fmt.Fprintf(conn, "OK\n")
+			}
+		}()
+	}
+}
+
+func TestQueuedPresses(t *testing.T) {
+	defer func(size int, age time.Duration) { QueueSize, QueueMaxAge = size, age }(QueueSize, QueueMaxAge)
+	QueueSize, QueueMaxAge = 10, 5*time.Second
+	defer func(addr string, opts []mpd.Option) { Configure(addr, opts...) }(mpdAddr, mpdOptions)
+	defer Shutdown()
+
+	// Find a free port; nothing listens on it until MPD "comes back".
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	Configure(addr)
+
+	press := func(p bool) func(*mpd.Client) error {
+		return func(c *mpd.Client) error { return c.Pause(p) }
+	}
+	if err := withQueuedMpdClient(press(true)); err != nil {
+		t.Fatalf("press while MPD is down: error = %v, want it queued", err)
+	}
+	queueMutex.Lock()
+	queued := len(queue)
+	queueMutex.Unlock()
+	if queued != 1 {
+		t.Fatalf("queue holds %d commands, want 1", queued)
+	}
+
+	var mu sync.Mutex
+	var got []string
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("listen again: %v", err)
+	}
+	defer ln.Close()
+	go serveMPD(ln, &mu, &got)
+
+	// MPD is back, but the queue hasn't been sent yet: the press must wait
+	// behind it.
+	if err := withQueuedMpdClient(press(false)); err != nil {
+		t.Fatalf("press while flushing: error = %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		queueMutex.Lock()
+		done := !flushing
+		queueMutex.Unlock()
+		if done || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"pause 1", "pause 0"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("MPD got %q, want %q", got, want)
+	}
+}
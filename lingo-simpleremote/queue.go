@@ -0,0 +1,131 @@
+package simpleremote
+
+import (
+	"sync"
+	"time"
+
+	"github.com/leo82309/ipod/mpd"
+)
+
+var (
+	// QueueSize is the number of button presses kept while MPD can't be
+	// reached, to be sent in order once it is back. When more arrive, the
+	// oldest are dropped. Zero, the default, disables queueing and presses
+	// fail right away, since replaying them later can be surprising.
+	QueueSize = 0
+	// QueueMaxAge is how long a queued press stays valid. Older ones are
+	// dropped instead of being sent.
+	QueueMaxAge = 5 * time.Second
+)
+
+// queueRetryInterval is the time between two attempts to send the queue.
+const queueRetryInterval = 250 * time.Millisecond
+
+// queuedCommand is a button press waiting for MPD to come back.
+type queuedCommand struct {
+	fn func(*mpd.Client) error
+	at time.Time
+}
+
+var (
+	queueMutex sync.Mutex
+	queue      []queuedCommand
+	// flushing is set while queued commands are pending, so that later
+	// presses are queued behind them instead of overtaking them.
+	flushing bool
+)
+
+// withQueuedMpdClient is like withMpdClient, but if MPD can't be reached
+// and QueueSize is set, fn is queued to be sent once it can.
+func withQueuedMpdClient(fn func(*mpd.Client) error) error {
+	if enqueueBehind(fn) {
+		return nil
+	}
+	err := withMpdClient(fn)
+	if err != nil && isConnError(err) && enqueue(fn) {
+		log.WithError(err).Info("SimpleRemote: mpd is unreachable, queued the command")
+		return nil
+	}
+	return err
+}
+
+// enqueueBehind queues fn if other commands are already waiting.
+func enqueueBehind(fn func(*mpd.Client) error) bool {
+	queueMutex.Lock()
+	defer queueMutex.Unlock()
+	if !flushing {
+		return false
+	}
+	pushCommand(queuedCommand{fn: fn, at: time.Now()})
+	return true
+}
+
+// enqueue queues fn and starts sending the queue in the background. It
+// returns false if queueing is disabled.
+func enqueue(fn func(*mpd.Client) error) bool {
+	queueMutex.Lock()
+	defer queueMutex.Unlock()
+	if QueueSize <= 0 {
+		return false
+	}
+	pushCommand(queuedCommand{fn: fn, at: time.Now()})
+	if !flushing {
+		flushing = true
+		go flushQueue()
+	}
+	return true
+}
+
+// pushCommand appends cmd to the queue, dropping the oldest commands if it
+// is full. queueMutex must be held.
+func pushCommand(cmd queuedCommand) {
+	queue = append(queue, cmd)
+	if over := len(queue) - QueueSize; over > 0 {
+		log.Warnf("SimpleRemote: command queue is full, dropped %d commands", over)
+		queue = append(queue[:0], queue[over:]...)
+	}
+}
+
+// dropExpired returns the commands that are younger than QueueMaxAge.
+func dropExpired(cmds []queuedCommand, now time.Time) []queuedCommand {
+	for len(cmds) > 0 && now.Sub(cmds[0].at) > QueueMaxAge {
+		cmds = cmds[1:]
+	}
+	return cmds
+}
+
+// flushQueue sends the queued commands in order as soon as MPD can be
+// reached, until the queue is empty.
+func flushQueue() {
+	for {
+		time.Sleep(queueRetryInterval)
+
+		queueMutex.Lock()
+		pending := dropExpired(queue, time.Now())
+		queue = nil
+		if len(pending) == 0 {
+			flushing = false
+			queueMutex.Unlock()
+			return
+		}
+		queueMutex.Unlock()
+
+		for i, cmd := range pending {
+			err := withMpdClient(cmd.fn)
+			if err != nil && isConnError(err) {
+				// Still unreachable; keep the rest for the next attempt.
+				queueMutex.Lock()
+				rest := queue
+				queue = nil
+				for _, cmd := range append(pending[i:], rest...) {
+					pushCommand(cmd)
+				}
+				queueMutex.Unlock()
+				break
+			}
+			if err != nil {
+				log.WithError(err).Warn("SimpleRemote: queued command failed")
+			}
+		}
+	}
+}
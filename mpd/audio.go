@@ -0,0 +1,91 @@
+package mpd
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Output is an audio output of the server.
+type Output struct {
+	ID      int
+	Name    string
+	Plugin  string // Output plugin, e.g. "alsa" or "httpd"
+	Enabled bool
+}
+
+// Outputs returns the audio outputs of the server.
+func (c *Client) Outputs() ([]Output, error) {
+	lines, err := c.sendCommand("outputs")
+	if err != nil {
+		return nil, err
+	}
+	return parseOutputs(lines), nil
+}
+
+// parseOutputs picks the output records out of lines.
+func parseOutputs(lines []string) []Output {
+	var outputs []Output
+	for _, line := range lines {
+		parts := strings.SplitN(line, ": ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key, value := parts[0], parts[1]
+		if key == "outputid" {
+			id, _ := strconv.Atoi(value)
+			outputs = append(outputs, Output{ID: id})
+			continue
+		}
+		if len(outputs) == 0 {
+			continue
+		}
+		o := &outputs[len(outputs)-1]
+		switch key {
+		case "outputname":
+			o.Name = value
+		case "plugin":
+			o.Plugin = value
+		case "outputenabled":
+			o.Enabled = value == "1"
+		}
+	}
+	return outputs
+}
+
+// ReplayGainMode returns the replay gain mode: "off", "track", "album" or
+// "auto".
+func (c *Client) ReplayGainMode() (string, error) {
+	lines, err := c.sendCommand("replay_gain_status")
+	if err != nil {
+		return "", err
+	}
+	return parseKVP(lines)["replay_gain_mode"], nil
+}
+
+// AudioSettings is what a sound settings screen shows, see
+// Client.AudioSettings.
+type AudioSettings struct {
+	Outputs        []Output
+	ReplayGainMode string // See Client.ReplayGainMode
+	Crossfade      int    // Crossfade in seconds, 0 if off
+	Format         string // Format of the playing audio, e.g. "44100:24:2", "" when stopped
+}
+
+// AudioSettings returns the outputs, the replay gain mode, the crossfade
+// and the current audio format in a single command list.
+func (c *Client) AudioSettings() (*AudioSettings, error) {
+	lines, err := c.sendCommandList("status", "outputs", "replay_gain_status")
+	if err != nil {
+		return nil, err
+	}
+	// The keys of the three responses don't overlap, so they can be told
+	// apart without list_OK markers.
+	kv := parseKVP(lines)
+	a := &AudioSettings{
+		Outputs:        parseOutputs(lines),
+		ReplayGainMode: kv["replay_gain_mode"],
+		Format:         kv["audio"],
+	}
+	a.Crossfade, _ = strconv.Atoi(kv["xfade"])
+	return a, nil
+}
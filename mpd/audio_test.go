@@ -0,0 +1,46 @@
+package mpd_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/leo82309/ipod/mpd"
+)
+
+func TestClient_AudioSettings(t *testing.T) {
+	c, srv := newTestClient(t, map[string]string{
+		"status": "state: play\nxfade: 5\naudio: 44100:24:2\n",
+		"outputs": "outputid: 0\noutputname: Speakers\nplugin: alsa\noutputenabled: 1\n" +
+			"attribute: allowed_formats=\n" +
+			"outputid: 1\noutputname: Stream\nplugin: httpd\noutputenabled: 0\n",
+		"replay_gain_status": "replay_gain_mode: album\n",
+	})
+
+	got, err := c.AudioSettings()
+	if err != nil {
+		t.Fatalf("AudioSettings() error = %v", err)
+	}
+	want := &mpd.AudioSettings{
+		Outputs: []mpd.Output{
+			{ID: 0, Name: "Speakers", Plugin: "alsa", Enabled: true},
+			{ID: 1, Name: "Stream", Plugin: "httpd"},
+		},
+		ReplayGainMode: "album",
+		Crossfade:      5,
+		Format:         "44100:24:2",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AudioSettings() = %+v, want %+v", got, want)
+	}
+	if cmds := srv.Commands(); len(cmds) != 3 {
+		t.Errorf("sent %q, want one command list of 3 commands", cmds)
+	}
+
+	outputs, err := c.Outputs()
+	if err != nil || !reflect.DeepEqual(outputs, want.Outputs) {
+		t.Errorf("Outputs() = %+v, %v, want %+v", outputs, err, want.Outputs)
+	}
+	if mode, err := c.ReplayGainMode(); err != nil || mode != "album" {
+		t.Errorf("ReplayGainMode() = %q, %v, want album", mode, err)
+	}
+}
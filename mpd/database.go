@@ -0,0 +1,250 @@
+package mpd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EntryType is the kind of a music database entry.
+type EntryType string
+
+const (
+	EntryFile      EntryType = "file"
+	EntryDirectory EntryType = "directory"
+	EntryPlaylist  EntryType = "playlist"
+)
+
+// Entry is an entry of the music database.
+type Entry struct {
+	Type EntryType
+	Path string
+	Song *Song // Metadata of a file entry, nil for other types
+}
+
+// Tag is the name of a song tag, or of a pseudo tag such as TagAny in
+// filters. MPD matches tag names case-insensitively.
+type Tag string
+
+const (
+	TagArtist      Tag = "Artist"
+	TagAlbumArtist Tag = "AlbumArtist"
+	TagAlbum       Tag = "Album"
+	TagTitle       Tag = "Title"
+	TagTrack       Tag = "Track"
+	TagDisc        Tag = "Disc"
+	TagGenre       Tag = "Genre"
+	TagDate        Tag = "Date"
+	TagComposer    Tag = "Composer"
+	TagName        Tag = "Name"
+
+	TagAny  Tag = "any"  // Any tag, in filters only
+	TagFile Tag = "file" // The song URI, in filters only
+	TagBase Tag = "base" // A directory prefix of the song URI, in filters only
+)
+
+// Filter matches songs whose Tag has Value, e.g. Filter{TagArtist, "Daft
+// Punk"}.
+type Filter struct {
+	Tag   Tag
+	Value string
+}
+
+// filterArgs formats criteria as the tag/value arguments of find-like
+// commands.
+func filterArgs(criteria []Filter) string {
+	var b strings.Builder
+	for _, f := range criteria {
+		b.WriteByte(' ')
+		b.WriteString(string(f.Tag))
+		b.WriteByte(' ')
+		b.WriteString(quoteArg(f.Value))
+	}
+	return b.String()
+}
+
+// Count returns the number of songs matching all criteria exactly and
+// their total playtime in seconds.
+func (c *Client) Count(criteria ...Filter) (songs int, playtime float64, err error) {
+	return c.count("count", criteria)
+}
+
+// SearchCount is like Count but matches case-insensitive substrings, as
+// the search command does.
+func (c *Client) SearchCount(criteria ...Filter) (songs int, playtime float64, err error) {
+	return c.count("searchcount", criteria)
+}
+
+func (c *Client) count(command string, criteria []Filter) (int, float64, error) {
+	lines, err := c.sendCommand(command + filterArgs(criteria))
+	if err != nil {
+		return 0, 0, err
+	}
+	kv := parseKVP(lines)
+	songs, _ := strconv.Atoi(kv["songs"])
+	playtime, _ := strconv.ParseFloat(kv["playtime"], 64)
+	return songs, playtime, nil
+}
+
+// Find returns the songs in the database matching all criteria exactly.
+func (c *Client) Find(criteria ...Filter) ([]Song, error) {
+	var songs []Song
+	isStart := func(line string) bool {
+		return strings.HasPrefix(line, "file: ")
+	}
+	err := c.sendRecordsFunc("find"+filterArgs(criteria), isStart, func(record []string) error {
+		songs = append(songs, parseSong(record))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return songs, nil
+}
+
+// ListDistinct is like List but returns each value once, sorted
+// case-insensitively. MPD repeats a value once per group, e.g. an album
+// title shared by several artists; use List for the raw values.
+func (c *Client) ListDistinct(tag Tag, criteria ...Filter) ([]string, error) {
+	values, err := c.list(tag, criteria)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(values, func(i, j int) bool {
+		a, b := strings.ToLower(values[i]), strings.ToLower(values[j])
+		if a != b {
+			return a < b
+		}
+		return values[i] < values[j]
+	})
+	unique := values[:0]
+	for _, v := range values {
+		if len(unique) == 0 || v != unique[len(unique)-1] {
+			unique = append(unique, v)
+		}
+	}
+	return unique, nil
+}
+
+// Artists returns the artists in the database, see ListDistinct.
+func (c *Client) Artists() ([]string, error) {
+	return c.ListDistinct(TagArtist)
+}
+
+// AlbumArtists returns the album artists in the database, see ListDistinct.
+func (c *Client) AlbumArtists() ([]string, error) {
+	return c.ListDistinct(TagAlbumArtist)
+}
+
+// Albums returns the albums of the songs matching all criteria, or of the
+// whole database if there are none, see ListDistinct.
+func (c *Client) Albums(criteria ...Filter) ([]string, error) {
+	return c.ListDistinct(TagAlbum, criteria...)
+}
+
+// Genres returns the genres in the database, see ListDistinct.
+func (c *Client) Genres() ([]string, error) {
+	return c.ListDistinct(TagGenre)
+}
+
+// ErrNoMatch is returned when no song in the database matches a request.
+var ErrNoMatch = errors.New("mpd: no matching songs")
+
+// PlayAlbum replaces the queue with the given album, ordered by disc and
+// track number, and starts playing it. It returns ErrNoMatch if the
+// database has no such album.
+func (c *Client) PlayAlbum(artist, album string) error {
+	songs, err := c.Find(Filter{TagArtist, artist}, Filter{TagAlbum, album})
+	if err != nil {
+		return err
+	}
+	if len(songs) == 0 {
+		return ErrNoMatch
+	}
+	sort.SliceStable(songs, func(i, j int) bool {
+		if songs[i].Disc != songs[j].Disc {
+			return songs[i].Disc < songs[j].Disc
+		}
+		return songs[i].Track < songs[j].Track
+	})
+
+	cmds := make([]string, 0, len(songs)+2)
+	cmds = append(cmds, "clear")
+	for _, s := range songs {
+		cmds = append(cmds, fmt.Sprintf("add %s", quoteArg(s.File)))
+	}
+	cmds = append(cmds, "play 0")
+	_, err = c.sendCommandList(cmds...)
+	return err
+}
+
+// ListAll returns the paths of all songs in the directory uri and its
+// subdirectories. Use "" for the whole database.
+func (c *Client) ListAll(uri string) ([]string, error) {
+	var paths []string
+	prefix := string(EntryFile) + ": "
+	err := c.sendCommandFunc(fmt.Sprintf("listall %s", quoteArg(uri)), func(line string) error {
+		if strings.HasPrefix(line, prefix) {
+			paths = append(paths, line[len(prefix):])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// ListAllInfo returns all entries in the directory uri and its
+// subdirectories, with metadata for songs. The result can be very large;
+// see ListAllInfoFunc to process entries one at a time instead.
+func (c *Client) ListAllInfo(uri string) ([]Entry, error) {
+	var entries []Entry
+	err := c.ListAllInfoFunc(uri, func(e Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ListAllInfoFunc calls fn for every entry in the directory uri and its
+// subdirectories as it is received. If fn returns an error, listing stops
+// and that error is returned. fn runs while c is busy with the listing, so
+// it must not use c: any call would block forever. Use a second Client to
+// fetch more data per entry.
+func (c *Client) ListAllInfoFunc(uri string, fn func(Entry) error) error {
+	cmd := fmt.Sprintf("listallinfo %s", quoteArg(uri))
+	return c.sendRecordsFunc(cmd, isEntryStart, func(record []string) error {
+		return fn(parseEntry(record))
+	})
+}
+
+// isEntryStart reports whether line starts a new database entry.
+func isEntryStart(line string) bool {
+	for _, t := range []EntryType{EntryFile, EntryDirectory, EntryPlaylist} {
+		if strings.HasPrefix(line, string(t)+": ") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseEntry builds an Entry from the lines of a single entry.
+func parseEntry(lines []string) Entry {
+	var e Entry
+	parts := strings.SplitN(lines[0], ": ", 2)
+	e.Type = EntryType(parts[0])
+	if len(parts) == 2 {
+		e.Path = parts[1]
+	}
+	if e.Type == EntryFile {
+		song := parseSong(lines)
+		e.Song = &song
+	}
+	return e
+}
@@ -0,0 +1,174 @@
+package mpd_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/leo82309/ipod/mpd"
+)
+
+var listAllInfoResponse = "directory: a\n" +
+	"Last-Modified: 2020-01-01T00:00:00Z\n" +
+	"file: a/1.flac\n" +
+	"Artist: A\n" +
+	"Title: One\n" +
+	"duration: 61.000\n" +
+	"file: a/2.flac\n" +
+	"Title: Two\n" +
+	"playlist: a/list.m3u\n"
+
+func TestClient_ListAll(t *testing.T) {
+	c, _ := newTestClient(t, map[string]string{
+		`listall ""`: "directory: a\nfile: a/1.flac\nfile: a/2.flac\n",
+	})
+	got, err := c.ListAll("")
+	if err != nil {
+		t.Fatalf("ListAll() error = %v", err)
+	}
+	if want := []string{"a/1.flac", "a/2.flac"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListAll() = %q, want %q", got, want)
+	}
+}
+
+func TestClient_ListAllInfo(t *testing.T) {
+	c, _ := newTestClient(t, map[string]string{
+		`listallinfo "a"`: listAllInfoResponse,
+	})
+	got, err := c.ListAllInfo("a")
+	if err != nil {
+		t.Fatalf("ListAllInfo() error = %v", err)
+	}
+	if len(got) != 4 {
+		t.Fatalf("ListAllInfo() returned %d entries, want 4", len(got))
+	}
+	if got[0].Type != mpd.EntryDirectory || got[0].Path != "a" || got[0].Song != nil {
+		t.Errorf("entry 0 = %+v", got[0])
+	}
+	if got[1].Type != mpd.EntryFile || got[1].Song == nil || got[1].Song.Title != "One" || got[1].Song.Duration != 61 {
+		t.Errorf("entry 1 = %+v", got[1])
+	}
+	if got[3].Type != mpd.EntryPlaylist || got[3].Path != "a/list.m3u" {
+		t.Errorf("entry 3 = %+v", got[3])
+	}
+}
+
+func TestClient_ListAllInfoFuncStop(t *testing.T) {
+	c, _ := newTestClient(t, map[string]string{
+		`listallinfo "a"`: listAllInfoResponse,
+	})
+	errStop := errors.New("stop")
+	n := 0
+	err := c.ListAllInfoFunc("a", func(mpd.Entry) error {
+		n++
+		if n == 2 {
+			return errStop
+		}
+		return nil
+	})
+	if err != errStop {
+		t.Errorf("ListAllInfoFunc() error = %v, want %v", err, errStop)
+	}
+	if n != 2 {
+		t.Errorf("fn called %d times, want 2", n)
+	}
+
+	// The rest of the response must have been consumed.
+	if _, err := c.Status(); err != nil {
+		t.Errorf("Status() after stop error = %v", err)
+	}
+}
+
+func TestClient_Count(t *testing.T) {
+	c, _ := newTestClient(t, map[string]string{
+		`count Artist "Daft Punk" Album "Discovery"`: "songs: 14\nplaytime: 3660\n",
+		`searchcount Title "one \"more\""`:           "songs: 2\nplaytime: 640.5\n",
+	})
+
+	songs, playtime, err := c.Count(mpd.Filter{mpd.TagArtist, "Daft Punk"}, mpd.Filter{mpd.TagAlbum, "Discovery"})
+	if err != nil || songs != 14 || playtime != 3660 {
+		t.Errorf("Count() = %d, %v, %v, want 14, 3660, nil", songs, playtime, err)
+	}
+	songs, playtime, err = c.SearchCount(mpd.Filter{mpd.TagTitle, `one "more"`})
+	if err != nil || songs != 2 || playtime != 640.5 {
+		t.Errorf("SearchCount() = %d, %v, %v, want 2, 640.5, nil", songs, playtime, err)
+	}
+}
+
+func TestClient_PlayAlbum(t *testing.T) {
+	c, srv := newTestClient(t, map[string]string{
+		`find Artist "A" Album "B"`: "file: b/2-1.flac\nTrack: 1\nDisc: 2\n" +
+			"file: b/1-2.flac\nTrack: 2/10\nDisc: 1/2\n" +
+			"file: b/1-1.flac\nTrack: 1/10\nDisc: 1/2\n",
+	})
+	if err := c.PlayAlbum("A", "B"); err != nil {
+		t.Fatalf("PlayAlbum() error = %v", err)
+	}
+	want := []string{`find Artist "A" Album "B"`, "clear", `add "b/1-1.flac"`, `add "b/1-2.flac"`, `add "b/2-1.flac"`, "play 0"}
+	if got := srv.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("PlayAlbum() sent %q, want %q", got, want)
+	}
+
+	if err := c.PlayAlbum("A", "Missing"); !errors.Is(err, mpd.ErrNoMatch) {
+		t.Errorf("PlayAlbum() error = %v, want ErrNoMatch", err)
+	}
+}
+
+func TestClient_Albums(t *testing.T) {
+	c, _ := newTestClient(t, map[string]string{
+		"list Album":              "Album: Greatest Hits\nAlbum: Abbey Road\nAlbum: Greatest Hits\n",
+		`list Album Genre "Rock"`: "Album: Abbey Road\n",
+	})
+
+	got, err := c.Albums()
+	if err != nil {
+		t.Fatalf("Albums() error = %v", err)
+	}
+	if want := []string{"Abbey Road", "Greatest Hits"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Albums() = %q, want %q", got, want)
+	}
+
+	got, err = c.Albums(mpd.Filter{Tag: mpd.TagGenre, Value: "Rock"})
+	if err != nil {
+		t.Fatalf("Albums() error = %v", err)
+	}
+	if want := []string{"Abbey Road"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Albums(Genre) = %q, want %q", got, want)
+	}
+}
+
+func TestClient_ListTagCase(t *testing.T) {
+	c, _ := newTestClient(t, map[string]string{
+		"list albumartist": "AlbumArtist: Air\nalbumartist: Beck\nAlbum: Moon Safari\n",
+	})
+
+	got, err := c.List("albumartist")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if want := []string{"Air", "Beck"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List() = %q, want %q", got, want)
+	}
+}
+
+func TestClient_ListDistinct(t *testing.T) {
+	c, _ := newTestClient(t, map[string]string{
+		"list artist": "Artist: beck\nArtist: Air\nArtist: Beck\nArtist: air\nArtist: Beck\n",
+	})
+
+	got, err := c.ListDistinct("artist")
+	if err != nil {
+		t.Fatalf("ListDistinct() error = %v", err)
+	}
+	if want := []string{"Air", "air", "Beck", "beck"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListDistinct() = %q, want %q", got, want)
+	}
+
+	raw, err := c.List("artist")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(raw) != 5 {
+		t.Errorf("List() = %q, want the 5 raw values", raw)
+	}
+}
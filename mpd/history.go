@@ -0,0 +1,69 @@
+package mpd
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// CommandRecord is an entry of the command history, see WithHistory.
+type CommandRecord struct {
+	Command string
+	Time    time.Time
+	Err     error // nil if MPD answered OK
+}
+
+// history is a fixed-size ring buffer of command records.
+// A nil history records nothing.
+type history struct {
+	mu      sync.Mutex
+	records []CommandRecord
+	next    int
+	full    bool
+}
+
+func newHistory(n int) *history {
+	if n <= 0 {
+		return nil
+	}
+	return &history{records: make([]CommandRecord, n)}
+}
+
+func (h *history) record(command string, err error) {
+	if h == nil {
+		return
+	}
+	if strings.HasPrefix(command, "password ") {
+		command = "password ***"
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records[h.next] = CommandRecord{Command: command, Time: time.Now(), Err: err}
+	h.next++
+	if h.next == len(h.records) {
+		h.next = 0
+		h.full = true
+	}
+}
+
+func (h *history) list() []CommandRecord {
+	if h == nil {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.full {
+		return append([]CommandRecord(nil), h.records[:h.next]...)
+	}
+	records := make([]CommandRecord, 0, len(h.records))
+	records = append(records, h.records[h.next:]...)
+	return append(records, h.records[:h.next]...)
+}
+
+// History returns the most recent commands sent by the client, oldest
+// first. It is empty unless the client was created with WithHistory.
+func (c *Client) History() []CommandRecord {
+	return c.history.list()
+}
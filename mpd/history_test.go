@@ -0,0 +1,44 @@
+package mpd_test
+
+import (
+	"testing"
+
+	"github.com/leo82309/ipod/mpd"
+)
+
+func TestClient_History(t *testing.T) {
+	srv := newFakeServer(t, map[string]string{
+		"status": "state: stop\nplaylistlength: 12\n",
+		"play 9": "ACK [2@0] {play} Bad song index\n",
+	})
+	c, err := mpd.NewClient(srv.Addr(), mpd.WithHistory(3), mpd.WithPassword("secret"))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer c.Close()
+
+	if got := c.History(); len(got) != 1 || got[0].Command != "password ***" {
+		t.Errorf("History() = %+v, want the redacted password command", got)
+	}
+
+	c.Pause(true)
+	c.Play(9)
+	got := c.History()
+	if len(got) != 3 {
+		t.Fatalf("History() has %d records, want 3", len(got))
+	}
+	if got[0].Command != "pause 1" || got[0].Err != nil {
+		t.Errorf("History()[0] = %+v", got[0])
+	}
+	if got[2].Command != "play 9" || got[2].Err == nil {
+		t.Errorf("History()[2] = %+v", got[2])
+	}
+}
+
+func TestClient_HistoryDisabled(t *testing.T) {
+	c, _ := newTestClient(t, nil)
+	c.Pause(true)
+	if got := c.History(); len(got) != 0 {
+		t.Errorf("History() = %+v, want none", got)
+	}
+}
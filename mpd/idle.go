@@ -0,0 +1,229 @@
+package mpd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Idle blocks until something changes in one of the given subsystems, such
+// as "player", "mixer" or "playlist", or in any subsystem if none are
+// given, and returns the names of the changed subsystems. If ctx is done
+// first, idle mode is left with noidle and ctx.Err() is returned; see
+// CancelIdle to end it from another goroutine without a context.
+//
+// The command timeout does not apply while waiting for a change.
+func (c *Client) Idle(ctx context.Context, subsystems ...string) ([]string, error) {
+	command := strings.TrimSpace("idle " + strings.Join(subsystems, " "))
+	changed, err := c.idleRoundTrip(ctx, command)
+	c.history.record(command, err)
+	return changed, err
+}
+
+func (c *Client) idleRoundTrip(ctx context.Context, command string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	idleCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	c.deadlineMu.Lock()
+	if c.closing {
+		c.deadlineMu.Unlock()
+		return nil, ErrClosed
+	}
+	c.cancelIdle = cancel
+	c.deadlineMu.Unlock()
+	defer func() {
+		c.deadlineMu.Lock()
+		c.cancelIdle = nil
+		c.deadlineMu.Unlock()
+	}()
+
+	if _, err := fmt.Fprintln(c.conn, command); err != nil {
+		return nil, fmt.Errorf("failed to send command '%s': %w", command, c.connFailed(err))
+	}
+
+	// Leave idle mode when ctx is done. MPD answers noidle like an idle
+	// that ended, so the response is read by the loop below. A noidle that
+	// arrives after idle ended on its own is ignored by the server.
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-idleCtx.Done():
+			c.deadlineMu.Lock()
+			defer c.deadlineMu.Unlock()
+			if c.closing {
+				// Close interrupts the read instead.
+				return
+			}
+			fmt.Fprintln(c.conn, "noidle")
+			if c.commandTimeout > 0 {
+				c.conn.SetReadDeadline(time.Now().Add(c.commandTimeout))
+			}
+		case <-done:
+		}
+	}()
+	defer func() {
+		close(done)
+		<-stopped
+		c.conn.SetReadDeadline(time.Time{})
+	}()
+
+	var changed []string
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			err = c.connFailed(err)
+			if idleCtx.Err() != nil {
+				return nil, c.idleErr(ctx)
+			}
+			return nil, fmt.Errorf("failed to read response for '%s': %w", command, err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "OK" {
+			break
+		}
+		if strings.HasPrefix(line, "ACK") {
+			return nil, fmt.Errorf("mpd command '%s' failed: %w", command, parseACK(line))
+		}
+		if strings.HasPrefix(line, "changed: ") {
+			changed = append(changed, strings.TrimPrefix(line, "changed: "))
+		}
+	}
+	if len(changed) == 0 && idleCtx.Err() != nil {
+		return nil, c.idleErr(ctx)
+	}
+	return changed, nil
+}
+
+// idleErr returns the reason an idle with the parent context ctx was left
+// early.
+func (c *Client) idleErr(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	if c.closing {
+		return ErrClosed
+	}
+	return ErrIdleCanceled
+}
+
+var (
+	// ErrIdleCanceled is returned by Idle when it was ended by CancelIdle.
+	ErrIdleCanceled = errors.New("mpd: idle canceled")
+	// ErrNotIdle is returned by CancelIdle when no Idle is in progress.
+	ErrNotIdle = errors.New("mpd: not idle")
+)
+
+// CancelIdle makes an Idle in progress on another goroutine leave idle
+// mode with noidle and return ErrIdleCanceled, e.g. to shut down an
+// idle-based watcher. The noidle is sent by the idling goroutine, so it
+// doesn't collide with other writes on the connection. CancelIdle returns
+// ErrNotIdle if the client isn't idling.
+func (c *Client) CancelIdle() error {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	if c.cancelIdle == nil {
+		return ErrNotIdle
+	}
+	c.cancelIdle()
+	return nil
+}
+
+// WaitForEnd blocks until playback stops by itself at the end of the queue,
+// or after the current song in single mode. Stopping playback by hand
+// elsewhere in the queue does not count; WaitForEnd keeps waiting.
+func (c *Client) WaitForEnd(ctx context.Context) error {
+	var prev *Status
+	for {
+		s, err := c.StatusContext(ctx)
+		if err != nil {
+			return err
+		}
+		if s.State == StateStop && prev != nil && prev.State == StatePlay && (prev.NextSongID < 0 || prev.Single) {
+			return nil
+		}
+		prev = s
+		if _, err := c.Idle(ctx, "player"); err != nil {
+			return err
+		}
+	}
+}
+
+const (
+	// idleRefreshInterval is how often IdleLoop fetches the status while
+	// playing, to advance Elapsed.
+	idleRefreshInterval = time.Second
+	// idleRetryInterval is the time IdleLoop waits before connecting again
+	// after the connection was lost.
+	idleRetryInterval = time.Second
+)
+
+// IdleLoop connects to the MPD server at addr and sends its status on the
+// returned channel whenever the player, the mixer or the playback options
+// change, which is far cheaper than polling. While playing, the status is
+// also refreshed every second for the elapsed time. A lost connection is
+// made again after a short pause. The channel is closed once ctx is done.
+func IdleLoop(ctx context.Context, addr string, opts ...Option) <-chan *Status {
+	statuses := make(chan *Status)
+	go func() {
+		defer close(statuses)
+		for {
+			err := idleLoop(ctx, addr, opts, statuses)
+			if ctx.Err() != nil {
+				return
+			}
+			log.WithError(err).Warnf("mpd: idling on %s failed. Retrying in %s...", addr, idleRetryInterval)
+			select {
+			case <-time.After(idleRetryInterval):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return statuses
+}
+
+// idleLoop runs IdleLoop on a single connection until it fails or ctx is
+// done.
+func idleLoop(ctx context.Context, addr string, opts []Option, statuses chan<- *Status) error {
+	client, err := NewClient(addr, opts...)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	for {
+		s, err := client.StatusContext(ctx)
+		if err != nil {
+			return err
+		}
+		select {
+		case statuses <- s:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		idleCtx, cancel := ctx, context.CancelFunc(func() {})
+		if s.State == StatePlay {
+			idleCtx, cancel = context.WithTimeout(ctx, idleRefreshInterval)
+		}
+		_, err = client.Idle(idleCtx, "player", "mixer", "options")
+		cancel()
+		if err != nil && idleCtx.Err() == nil {
+			return err
+		}
+	}
+}
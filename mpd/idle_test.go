@@ -0,0 +1,199 @@
+package mpd_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leo82309/ipod/mpd"
+)
+
+func TestClient_Idle(t *testing.T) {
+	c, _ := newTestClient(t, map[string]string{
+		"idle player mixer": "changed: player\nchanged: mixer\n",
+	})
+	got, err := c.Idle(context.Background(), "player", "mixer")
+	if err != nil {
+		t.Fatalf("Idle() error = %v", err)
+	}
+	if want := []string{"player", "mixer"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Idle() = %q, want %q", got, want)
+	}
+}
+
+func TestClient_IdleCanceled(t *testing.T) {
+	c, err := mpd.NewClient(newSilentServer(t), mpd.WithCommandTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := c.Idle(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Idle() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestClient_CancelIdle(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	noidle := make(chan struct{})
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(conn, "OK MPD 0.23.5\n")
+		r := bufio.NewReader(conn)
+		// Stay idle until noidle arrives, which ends it without changes.
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if strings.TrimSpace(line) == "noidle" {
+				close(noidle)
+				fmt.Fprintf(conn, "OK\n")
+			}
+		}
+	}()
+
+	c, err := mpd.NewClient(ln.Addr().String())
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer c.Close()
+
+	if err := c.CancelIdle(); !errors.Is(err, mpd.ErrNotIdle) {
+		t.Errorf("CancelIdle() before Idle error = %v, want ErrNotIdle", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Idle(context.Background())
+		done <- err
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for c.CancelIdle() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Idle did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, mpd.ErrIdleCanceled) {
+			t.Errorf("Idle() error = %v, want ErrIdleCanceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Idle() did not return after CancelIdle")
+	}
+	select {
+	case <-noidle:
+	default:
+		t.Error("CancelIdle did not send noidle")
+	}
+	if !c.IsConnected() {
+		t.Error("IsConnected() = false after CancelIdle, want the connection kept")
+	}
+}
+
+func TestClient_CloseDuringIdle(t *testing.T) {
+	c, err := mpd.NewClient(newSilentServer(t))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Idle(context.Background())
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		c.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close() blocked on Idle")
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, mpd.ErrClosed) {
+			t.Errorf("Idle() error = %v, want ErrClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Idle() did not return after Close")
+	}
+}
+
+func TestClient_WaitForEnd(t *testing.T) {
+	c, srv := newTestClient(t, map[string]string{
+		"status": "state: play\nsong: 4\nsongid: 5\n",
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- c.WaitForEnd(context.Background()) }()
+
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("WaitForEnd() returned %v while playing", err)
+	default:
+	}
+
+	srv.Set("status", "state: stop\n")
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("WaitForEnd() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WaitForEnd() did not return after playback ended")
+	}
+}
+
+func TestIdleLoop(t *testing.T) {
+	srv := newFakeServer(t, map[string]string{
+		"status":                    "state: stop\n",
+		"idle player mixer options": "changed: player\n",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	statuses := mpd.IdleLoop(ctx, srv.Addr())
+
+	s := <-statuses
+	if s.State != mpd.StateStop {
+		t.Errorf("first status = %q, want %q", s.State, mpd.StateStop)
+	}
+	srv.Set("status", "state: pause\n")
+	timeout := time.After(2 * time.Second)
+	for s.State != mpd.StatePause {
+		select {
+		case s = <-statuses:
+		case <-timeout:
+			t.Fatal("IdleLoop did not send the changed status")
+		}
+	}
+
+	cancel()
+	for range statuses {
+	}
+}
@@ -2,18 +2,97 @@ package mpd
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
 	"net"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
+var log logrus.FieldLogger = logrus.StandardLogger()
+
+// SetLogger sets the logger used by the package. By default the logrus
+// standard logger is used, so its level also applies here.
+func SetLogger(l logrus.FieldLogger) {
+	log = l
+}
+
+// DefaultAddr is the address MPD listens on by default.
+const DefaultAddr = "127.0.0.1:6600"
+
+// Client is a connection to an MPD server.
+//
+// The methods ending in Context give up when their ctx is done. If that
+// happens while a command is waiting for the server, the rest of the
+// response can't be told apart from the next one, so the connection is
+// closed: IsConnected reports false, later calls fail with
+// ErrConnectionLost and the Client must be replaced.
 type Client struct {
 	conn   net.Conn
 	reader *bufio.Reader
+
+	// mu serializes round trips with each other and with Close. closed is
+	// set by Close.
+	mu     sync.Mutex
+	closed bool
+
+	// broken is set atomically once the connection is closed or failed,
+	// see IsConnected.
+	broken int32
+
+	// version is the protocol version announced in the welcome message.
+	version Version
+
+	// binaryLimit caps the size of binary responses, see WithBinaryLimit.
+	binaryLimit int
+
+	// commandTimeout bounds each read of a response, see WithCommandTimeout.
+	commandTimeout time.Duration
+
+	// history records recent commands if enabled with WithHistory.
+	history *history
+
+	// local is set for connections over a unix socket.
+	local bool
+
+	// rawStatus keeps the raw status response, see WithRawStatus.
+	rawStatus bool
+
+	// ctx is the context of the command in flight, if any, see
+	// bindContext. deadlineMu serializes its deadline updates.
+	ctx        context.Context
+	deadlineMu sync.Mutex
+
+	// cancelIdle ends the running Idle, if any, see CancelIdle. closing is
+	// set once Close was called. Both are guarded by deadlineMu.
+	cancelIdle context.CancelFunc
+	closing    bool
+
+	// song caches the metadata of the last song fetched by Status so that
+	// repeated polls of the same song can skip the currentsong round-trip.
+	// It is guarded by songMu.
+	song   songCache
+	songMu sync.Mutex
+}
+
+// songCache holds the currentsong metadata for a given song id, state and
+// queue version. The queue version changes with the tags of a queued song,
+// e.g. after AddTagID.
+type songCache struct {
+	valid           bool
+	songID          int
+	state           State
+	playlistVersion int
+	artist          string
+	album           string
+	title           string // Empty if the song has neither Title nor Name
 }
 
 var (
@@ -21,29 +100,168 @@ var (
 	statusMutex   sync.RWMutex
 )
 
+// State is the playback state of the player.
+type State string
+
+const (
+	StatePlay  State = "play"
+	StatePause State = "pause"
+	StateStop  State = "stop"
+)
+
 type Status struct {
-	State          string // e.g., "play", "pause", "stop"
-	Volume         int    // 0-100 or -1 if unavailable
-	Repeat         bool   // Repeat mode
-	Random         bool   // Random mode
-	Single         bool   // Single mode
-	Consume        bool   // Consume mode
-	PlaylistLength int
-	Song           int
-	SongID         int // Current song ID
-	NextSong       int
-	NextSongID     int
-	Duration       int
-	Elapsed        float64 // Elapsed time of current song
-	Bitrate        int     // kbit/s
-	Error          string  // If an error occurred
-	Artist         string
-	Album          string
-	Title          string
-}
-
-func NewClient(addr string) (*Client, error) {
-	conn, err := net.Dial("tcp", addr)
+	State           State
+	Volume          int  // 0-100 or -1 if unavailable
+	VolumeAvailable bool // Whether the mixer reported a volume
+	Repeat          bool // Repeat mode
+	Random          bool // Random mode
+	Single          bool // Single mode, also set in oneshot mode
+	SingleOneshot   bool // Single mode is reset after the current song
+	Consume         bool // Consume mode, also set in oneshot mode
+	ConsumeOneshot  bool // Consume mode is reset after the current song
+	PlaylistLength  int
+	PlaylistVersion int // Queue version, incremented on every change
+	Song            int
+	SongID          int // Current song ID
+	NextSong        int
+	NextSongID      int
+	Duration        int
+	Elapsed         float64 // Elapsed time of current song
+	IsStream        bool    // Current song has no duration, e.g. a radio stream
+	Bitrate         int     // kbit/s
+	Error           string  // If an error occurred
+	Partition       string  // Partition the client controls, "" if the server has none
+	Artist          string
+	Album           string
+	Title           string
+
+	// Raw holds all key/value pairs of the status response, including
+	// keys not modelled above, if enabled with WithRawStatus. It is best
+	// effort and not part of the stable API: keys and value formats are
+	// whatever the server sends and may change between MPD versions.
+	Raw map[string]string
+
+	FetchedAt time.Time // When the status was received from MPD
+}
+
+// Progress returns the position within the current song as a fraction
+// between 0 and 1. It is 0 for streams, which have no duration.
+func (s *Status) Progress() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	p := s.Elapsed / float64(s.Duration)
+	if p > 1 {
+		p = 1
+	}
+	return p
+}
+
+// ElapsedAt estimates the elapsed time of the current song at now, given
+// that the status was fetched at fetchedAt. While playing, Elapsed is
+// advanced by the wall-clock time in between, capped at Duration; in any
+// other state Elapsed is returned unchanged.
+func (s *Status) ElapsedAt(now time.Time, fetchedAt time.Time) float64 {
+	if s.State != StatePlay || now.Before(fetchedAt) {
+		return s.Elapsed
+	}
+	elapsed := s.Elapsed + now.Sub(fetchedAt).Seconds()
+	if s.Duration > 0 && elapsed > float64(s.Duration) {
+		elapsed = float64(s.Duration)
+	}
+	return elapsed
+}
+
+// Option configures a Client created by NewClient.
+type Option func(*options)
+
+type options struct {
+	password       string
+	dialTimeout    time.Duration
+	keepAlive      time.Duration
+	binaryLimit    int
+	historySize    int
+	commandTimeout time.Duration
+	rawStatus      bool
+}
+
+// defaultBinaryLimit is the largest binary response (e.g. album art) a Client
+// accepts unless configured otherwise with WithBinaryLimit.
+const defaultBinaryLimit = 16 << 20
+
+// defaultCommandTimeout is how long a Client waits for each line of a
+// response unless configured otherwise with WithCommandTimeout.
+const defaultCommandTimeout = 10 * time.Second
+
+// WithPassword authenticates with the given password right after connecting.
+func WithPassword(password string) Option {
+	return func(o *options) {
+		o.password = password
+	}
+}
+
+// WithDialTimeout limits how long NewClient waits for the connection to be
+// established. Zero means no timeout.
+func WithDialTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.dialTimeout = d
+	}
+}
+
+// WithKeepAlive sets the TCP keep-alive period of the connection.
+// A negative value disables keep-alives.
+func WithKeepAlive(d time.Duration) Option {
+	return func(o *options) {
+		o.keepAlive = d
+	}
+}
+
+// WithCommandTimeout limits how long the client waits for the server
+// while sending a command and reading each part of its response. A server
+// that stalls longer makes the command fail with a timeout error and the
+// connection is closed, since it is out of sync afterwards. Zero disables
+// the timeout. It is independent of the dial timeout.
+func WithCommandTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.commandTimeout = d
+	}
+}
+
+// WithBinaryLimit sets the maximum size in bytes of a binary response
+// the client is willing to read.
+func WithBinaryLimit(n int) Option {
+	return func(o *options) {
+		o.binaryLimit = n
+	}
+}
+
+// WithHistory makes the client remember the last n commands and their
+// outcome, see Client.History. History is off by default.
+func WithHistory(n int) Option {
+	return func(o *options) {
+		o.historySize = n
+	}
+}
+
+// WithRawStatus makes the client fill in Status.Raw.
+func WithRawStatus() Option {
+	return func(o *options) {
+		o.rawStatus = true
+	}
+}
+
+func NewClient(addr string, opts ...Option) (*Client, error) {
+	o := options{binaryLimit: defaultBinaryLimit, commandTimeout: defaultCommandTimeout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	network, addr, err := parseAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	dialer := net.Dialer{Timeout: o.dialTimeout, KeepAlive: o.keepAlive}
+	conn, err := dialer.Dial(network, addr)
 	if err != nil {
 		return nil, fmt.Errorf("could not connect to MPD at %s: %w", addr, err)
 	}
@@ -51,7 +269,11 @@ func NewClient(addr string) (*Client, error) {
 	reader := bufio.NewReader(conn)
 
 	// Read the initial "OK MPD" line
+	if o.commandTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(o.commandTimeout))
+	}
 	line, err := reader.ReadString('\n')
+	conn.SetDeadline(time.Time{})
 	if err != nil {
 		conn.Close()
 		return nil, fmt.Errorf("failed to read MPD welcome message: %w", err)
@@ -59,17 +281,245 @@ func NewClient(addr string) (*Client, error) {
 
 	if !strings.HasPrefix(line, "OK MPD") {
 		conn.Close()
-		return nil, fmt.Errorf("unexpected MPD welcome message: %s", line)
+		return nil, fmt.Errorf("%w: unexpected welcome message %q", ErrNotMPD, strings.TrimSpace(line))
+	}
+
+	version, err := parseVersion(strings.TrimSpace(strings.TrimPrefix(line, "OK MPD")))
+	if err != nil {
+		log.WithError(err).Warn("mpd: could not parse server version")
+	}
+
+	c := &Client{
+		conn:           conn,
+		reader:         reader,
+		version:        version,
+		binaryLimit:    o.binaryLimit,
+		commandTimeout: o.commandTimeout,
+		history:        newHistory(o.historySize),
+		local:          network == "unix",
+		rawStatus:      o.rawStatus,
+	}
+
+	if o.password != "" {
+		if _, err := c.sendCommand(fmt.Sprintf("password %s", quoteArg(o.password))); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Version is an MPD protocol version such as 0.23.5.
+type Version struct {
+	Major, Minor, Patch int
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// AtLeast reports whether v is the same as or newer than other.
+func (v Version) AtLeast(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major > other.Major
+	}
+	if v.Minor != other.Minor {
+		return v.Minor > other.Minor
+	}
+	return v.Patch >= other.Patch
+}
+
+// parseVersion parses a "major.minor.patch" version string.
+// The patch part is optional.
+func parseVersion(s string) (Version, error) {
+	var v Version
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return v, fmt.Errorf("invalid version %q", s)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid version %q", s)
+		}
+		*nums[i] = n
+	}
+	return v, nil
+}
+
+// ServerVersion returns the protocol version announced by the server,
+// e.g. "0.23.5".
+func (c *Client) ServerVersion() string {
+	return c.version.String()
+}
+
+// Version returns the protocol version announced by the server for
+// comparisons, see Version.AtLeast.
+func (c *Client) Version() Version {
+	return c.version
+}
+
+// isSocketPath reports whether addr names a unix socket rather than a TCP
+// address: an absolute path, or an abstract socket starting with "@".
+func isSocketPath(addr string) bool {
+	return strings.HasPrefix(addr, "/") || strings.HasPrefix(addr, "@")
+}
+
+// defaultPort is the port used for TCP addresses without one.
+const defaultPort = "6600"
+
+// ErrInvalidAddr is returned by NewClient for malformed addresses.
+var ErrInvalidAddr = errors.New("mpd: invalid address")
+
+// ErrNotMPD is returned by NewClient when the server does not greet like
+// MPD, e.g. because the address points at another service.
+var ErrNotMPD = errors.New("mpd: not an MPD server")
+
+// parseAddr returns the network and address to dial for addr, which is
+// either a unix socket path or a TCP address. The default port is added to
+// TCP addresses without one, e.g. "localhost" or "::1".
+func parseAddr(addr string) (network, address string, err error) {
+	if isSocketPath(addr) {
+		return "unix", addr, nil
+	}
+	if addr == "" {
+		return "", "", fmt.Errorf("%w: empty address", ErrInvalidAddr)
+	}
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		// A bare host name or IP address, including IPv6 without brackets.
+		if strings.Contains(addr, ":") && net.ParseIP(addr) == nil {
+			return "", "", fmt.Errorf("%w %q: %v", ErrInvalidAddr, addr, err)
+		}
+		return "tcp", net.JoinHostPort(addr, defaultPort), nil
+	}
+	if port == "" {
+		return "", "", fmt.Errorf("%w %q: empty port", ErrInvalidAddr, addr)
+	}
+	return "tcp", addr, nil
+}
+
+// ErrNotLocal is returned by commands that MPD only allows for clients
+// connected over a unix socket.
+var ErrNotLocal = errors.New("mpd: command needs a local socket connection")
+
+// Config returns the server configuration reported by the config command,
+// such as music_directory. MPD only answers it on a unix socket, so
+// ErrNotLocal is returned for TCP connections.
+func (c *Client) Config() (map[string]string, error) {
+	if !c.local {
+		return nil, ErrNotLocal
+	}
+	lines, err := c.sendCommand("config")
+	if err != nil {
+		return nil, err
 	}
+	return parseKVP(lines), nil
+}
+
+// MusicDirectory returns the music directory of the server, which song
+// URIs are relative to. It has the same restrictions as Config.
+func (c *Client) MusicDirectory() (string, error) {
+	cfg, err := c.Config()
+	if err != nil {
+		return "", err
+	}
+	dir, ok := cfg["music_directory"]
+	if !ok {
+		return "", errors.New("mpd: config has no music_directory")
+	}
+	return dir, nil
+}
+
+// Feature is a protocol feature that is only available on newer servers.
+type Feature int
+
+const (
+	FeatureAlbumArt       Feature = iota // albumart command
+	FeatureReadPicture                   // readpicture command
+	FeatureSingleOneshot                 // single oneshot mode
+	FeatureConsumeOneshot                // consume oneshot mode
+)
+
+var features = map[Feature]struct {
+	name    string
+	version Version
+}{
+	FeatureAlbumArt:       {"albumart", Version{0, 21, 0}},
+	FeatureReadPicture:    {"readpicture", Version{0, 22, 0}},
+	FeatureSingleOneshot:  {"single oneshot", Version{0, 21, 0}},
+	FeatureConsumeOneshot: {"consume oneshot", Version{0, 24, 0}},
+}
+
+func (f Feature) String() string {
+	if info, ok := features[f]; ok {
+		return info.name
+	}
+	return fmt.Sprintf("Feature(%d)", int(f))
+}
 
-	return &Client{
-		conn:   conn,
-		reader: reader,
-	}, nil
+// ErrUnsupported matches (with errors.Is) the errors returned when the
+// server is too old for a command.
+var ErrUnsupported = errors.New("mpd: not supported by server")
+
+// UnsupportedError is returned when a command needs a newer server.
+type UnsupportedError struct {
+	Feature  Feature
+	Required Version // Minimum server version for Feature
+	Server   Version // Version of the connected server
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("mpd: %s requires MPD %s, server is %s", e.Feature, e.Required, e.Server)
+}
+
+// Is makes UnsupportedError match ErrUnsupported.
+func (e *UnsupportedError) Is(target error) bool {
+	return target == ErrUnsupported
+}
+
+// Supports reports whether the connected server is new enough for the
+// given feature. If the server version is unknown, every feature is
+// assumed to be supported.
+func (c *Client) Supports(f Feature) bool {
+	return c.require(f) == nil
 }
 
-// Close disconnects from the MPD server.
+// require returns an UnsupportedError if the server is too old for f.
+func (c *Client) require(f Feature) error {
+	info, ok := features[f]
+	if !ok || c.version == (Version{}) || c.version.AtLeast(info.version) {
+		return nil
+	}
+	return &UnsupportedError{Feature: f, Required: info.version, Server: c.version}
+}
+
+// ErrClosed is returned by commands sent after Close.
+var ErrClosed = errors.New("mpd: client is closed")
+
+// Close disconnects from the MPD server. A command in flight on another
+// goroutine is allowed to finish first, except for Idle, which could wait
+// for a change indefinitely and is interrupted. Commands sent afterwards
+// fail with ErrClosed.
 func (c *Client) Close() error {
+	c.deadlineMu.Lock()
+	c.closing = true
+	if c.cancelIdle != nil {
+		// Idle holds c.mu until the server reports a change.
+		c.conn.SetDeadline(aLongTimeAgo)
+		c.cancelIdle()
+	}
+	c.deadlineMu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	atomic.StoreInt32(&c.broken, 1)
 	if c.conn != nil {
 		return c.conn.Close()
 	}
@@ -78,17 +528,66 @@ func (c *Client) Close() error {
 
 // sendCommand sends a command to MPD and returns the response lines.
 func (c *Client) sendCommand(command string) ([]string, error) {
+	return c.sendCommandContext(context.Background(), command)
+}
+
+// sendCommandContext is like sendCommand but gives up when ctx is done.
+func (c *Client) sendCommandContext(ctx context.Context, command string) ([]string, error) {
+	response, err := c.roundTrip(ctx, command)
+	c.history.record(command, err)
+	return response, err
+}
+
+// roundTrip writes a command and reads its response.
+func (c *Client) roundTrip(ctx context.Context, command string) ([]string, error) {
+	var response []string
+	err := c.streamRoundTrip(ctx, command, func(line string) error {
+		response = append(response, line)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// sendCommandFunc sends a command to MPD and passes each response line to
+// fn as it is read, without buffering the whole response. If fn returns an
+// error, the rest of the response is read and discarded and that error is
+// returned.
+func (c *Client) sendCommandFunc(command string, fn func(line string) error) error {
+	err := c.streamRoundTrip(context.Background(), command, fn)
+	c.history.record(command, err)
+	return err
+}
+
+// streamRoundTrip writes a command and passes its response lines to fn.
+// If ctx is done first, the command is interrupted and ctx.Err() returned.
+func (c *Client) streamRoundTrip(ctx context.Context, command string, fn func(line string) error) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	defer c.bindContext(ctx, &err)()
+	defer c.clearDeadline()
+
 	// Send the command with a newline
-	_, err := fmt.Fprintln(c.conn, command)
+	c.extendDeadline()
+	_, err = fmt.Fprintln(c.conn, command)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send command '%s': %w", command, err)
+		return fmt.Errorf("failed to send command '%s': %w", command, c.connFailed(err))
 	}
 
-	var response []string
+	var fnErr error
 	for {
+		c.extendDeadline()
 		line, err := c.reader.ReadString('\n')
 		if err != nil {
-			return nil, fmt.Errorf("failed to read response for '%s': %w", command, err)
+			return fmt.Errorf("failed to read response for '%s': %w", command, c.connFailed(err))
 		}
 
 		line = strings.TrimSpace(line)
@@ -100,13 +599,396 @@ func (c *Client) sendCommand(command string) ([]string, error) {
 
 		// Check for an error response
 		if strings.HasPrefix(line, "ACK") {
-			return nil, fmt.Errorf("mpd command '%s' failed: %s", command, line)
+			return fmt.Errorf("mpd command '%s' failed: %w", command, parseACK(line))
 		}
 
-		response = append(response, line)
+		// Keep reading after fn failed to leave the connection in sync.
+		if fnErr == nil {
+			fnErr = fn(line)
+		}
 	}
 
-	return response, nil
+	return fnErr
+}
+
+// extendDeadline gives the server another commandTimeout to respond, or
+// less if the bound context expires earlier.
+func (c *Client) extendDeadline() {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	var deadline time.Time
+	if c.commandTimeout > 0 {
+		deadline = time.Now().Add(c.commandTimeout)
+	}
+	if c.ctx != nil {
+		if c.ctx.Err() != nil {
+			deadline = aLongTimeAgo
+		} else if d, ok := c.ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+			deadline = d
+		}
+	}
+	if !deadline.IsZero() {
+		c.conn.SetDeadline(deadline)
+	}
+}
+
+// clearDeadline removes the deadline set by extendDeadline, so that an idle
+// connection does not time out between commands.
+func (c *Client) clearDeadline() {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	if c.ctx != nil && c.ctx.Err() != nil {
+		// Keep interrupting; the deadline is reset when the context is
+		// released.
+		return
+	}
+	c.conn.SetDeadline(time.Time{})
+}
+
+// aLongTimeAgo is a deadline in the past, used to interrupt a blocked read.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// bindContext makes the command in flight give up as soon as ctx is done.
+// c.mu must be held until the returned function has been called, so that
+// ctx only ever interrupts its own command. Calling the returned function
+// replaces *errp with ctx.Err() if the command failed because of ctx.
+// Binding a context that can't be cancelled does nothing.
+//
+// Use it as
+//
+//	defer c.bindContext(ctx, &err)()
+func (c *Client) bindContext(ctx context.Context, errp *error) func() {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	c.deadlineMu.Lock()
+	c.ctx = ctx
+	c.deadlineMu.Unlock()
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		select {
+		case <-ctx.Done():
+			c.deadlineMu.Lock()
+			c.conn.SetDeadline(aLongTimeAgo)
+			c.deadlineMu.Unlock()
+		case <-stop:
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+		c.deadlineMu.Lock()
+		c.ctx = nil
+		c.conn.SetDeadline(time.Time{})
+		c.deadlineMu.Unlock()
+		if *errp == nil {
+			return
+		}
+		if d, ok := ctx.Deadline(); ok && !time.Now().Before(d) {
+			// The connection deadline can expire just before ctx does.
+			<-ctx.Done()
+		}
+		if ctx.Err() != nil {
+			*errp = ctx.Err()
+		}
+	}
+}
+
+// ErrConnectionLost is returned, wrapping the I/O error, when the server
+// closed or reset the connection, possibly in the middle of a response.
+// The client can't be used anymore; connect again with NewClient.
+var ErrConnectionLost = errors.New("mpd: connection lost")
+
+// connLostError wraps an I/O error that ended the connection.
+type connLostError struct {
+	err error
+}
+
+func (e *connLostError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrConnectionLost, e.err)
+}
+
+func (e *connLostError) Unwrap() error {
+	return e.err
+}
+
+// Is makes errors.Is(err, ErrConnectionLost) match.
+func (e *connLostError) Is(target error) bool {
+	return target == ErrConnectionLost
+}
+
+// connFailed marks the connection as broken after the I/O error err and
+// closes it: after a timeout the rest of the response may still arrive
+// later, so the connection can't be reused, and any other error means it
+// is gone. It returns err, wrapped as ErrConnectionLost unless it is a
+// timeout.
+func (c *Client) connFailed(err error) error {
+	atomic.StoreInt32(&c.broken, 1)
+	c.conn.Close()
+	var netErr net.Error
+	if err == nil || errors.As(err, &netErr) && netErr.Timeout() {
+		return err
+	}
+	return &connLostError{err: err}
+}
+
+// IsConnected reports whether the client has a usable connection: it has
+// not been closed and no command failed to reach the server. It does not
+// contact the server.
+func (c *Client) IsConnected() bool {
+	return atomic.LoadInt32(&c.broken) == 0
+}
+
+// sendCommandList sends the commands as a single command list. MPD executes
+// them in order and stops at the first failing one; its position in the
+// list is reported in the ACKError.
+func (c *Client) sendCommandList(commands ...string) ([]string, error) {
+	var b strings.Builder
+	b.WriteString("command_list_begin\n")
+	for _, cmd := range commands {
+		b.WriteString(cmd)
+		b.WriteByte('\n')
+	}
+	b.WriteString("command_list_end")
+	return c.sendCommand(b.String())
+}
+
+// sendBinaryCommand sends a command whose response carries a binary chunk,
+// such as albumart or readpicture. It returns the key/value pairs of the
+// response and the chunk, which is nil if the response had no binary part.
+func (c *Client) sendBinaryCommand(command string) (map[string]string, []byte, error) {
+	kv, chunk, err := c.binaryRoundTrip(context.Background(), command)
+	c.history.record(command, err)
+	return kv, chunk, err
+}
+
+// binaryRoundTrip writes a command and reads its binary response. If ctx is
+// done first, the command is interrupted and ctx.Err() returned.
+func (c *Client) binaryRoundTrip(ctx context.Context, command string) (_ map[string]string, _ []byte, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, nil, ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	defer c.bindContext(ctx, &err)()
+	defer c.clearDeadline()
+
+	c.extendDeadline()
+	_, err = fmt.Fprintln(c.conn, command)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to send command '%s': %w", command, c.connFailed(err))
+	}
+
+	kv := make(map[string]string)
+	var chunk []byte
+	for {
+		c.extendDeadline()
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read response for '%s': %w", command, c.connFailed(err))
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "OK" {
+			break
+		}
+		if strings.HasPrefix(line, "ACK") {
+			return nil, nil, fmt.Errorf("mpd command '%s' failed: %w", command, parseACK(line))
+		}
+
+		parts := strings.SplitN(line, ": ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if parts[0] != "binary" {
+			kv[parts[0]] = parts[1]
+			continue
+		}
+
+		n, err := strconv.Atoi(parts[1])
+		if err != nil || n < 0 {
+			c.connFailed(nil)
+			return nil, nil, fmt.Errorf("invalid binary length for '%s': %s", command, parts[1])
+		}
+		if n > c.binaryLimit {
+			// The chunk can't be skipped without reading it, so the
+			// connection is out of sync from here on.
+			c.connFailed(nil)
+			return nil, nil, fmt.Errorf("binary chunk of %d bytes for '%s' exceeds limit of %d bytes", n, command, c.binaryLimit)
+		}
+
+		// The chunk is followed by a single newline.
+		chunk = make([]byte, n+1)
+		c.extendDeadline()
+		if _, err := io.ReadFull(c.reader, chunk); err != nil {
+			return nil, nil, fmt.Errorf("failed to read binary response for '%s': %w", command, c.connFailed(err))
+		}
+		chunk = chunk[:n]
+	}
+
+	return kv, chunk, nil
+}
+
+// readBinary repeatedly issues a binary command for uri, advancing the
+// offset until the whole object has been read. It returns nil data if the
+// server has no binary data for uri. The declared size is checked against
+// the client's binary limit before anything is buffered.
+func (c *Client) readBinary(command, uri string) ([]byte, map[string]string, error) {
+	var data []byte
+	var meta map[string]string
+	for {
+		cmd := fmt.Sprintf("%s %s %d", command, quoteArg(uri), len(data))
+		kv, chunk, err := c.sendBinaryCommand(cmd)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		sizeStr, ok := kv["size"]
+		if !ok {
+			return nil, nil, nil
+		}
+		size, err := strconv.Atoi(sizeStr)
+		if err != nil || size < 0 {
+			return nil, nil, fmt.Errorf("invalid size for '%s': %s", cmd, sizeStr)
+		}
+		if size > c.binaryLimit {
+			return nil, nil, fmt.Errorf("binary response of %d bytes for '%s' exceeds limit of %d bytes", size, cmd, c.binaryLimit)
+		}
+
+		if meta == nil {
+			meta = kv
+			data = make([]byte, 0, size)
+		}
+		if len(data)+len(chunk) > size {
+			return nil, nil, fmt.Errorf("binary response for '%s' is larger than its declared size of %d bytes", cmd, size)
+		}
+		data = append(data, chunk...)
+
+		if len(data) == size {
+			break
+		}
+		if len(chunk) == 0 {
+			return nil, nil, fmt.Errorf("binary response for '%s' ended after %d of %d bytes", cmd, len(data), size)
+		}
+	}
+	return data, meta, nil
+}
+
+// AlbumArt returns the cover image stored next to the song with the given
+// URI (e.g. cover.jpg in its directory), or nil if there is none.
+func (c *Client) AlbumArt(uri string) ([]byte, error) {
+	if err := c.require(FeatureAlbumArt); err != nil {
+		return nil, err
+	}
+	data, _, err := c.readBinary("albumart", uri)
+	return data, err
+}
+
+// ReadPicture returns the picture embedded in the song with the given URI
+// and its MIME type if MPD reports one. It returns nil if the song has no
+// embedded picture.
+func (c *Client) ReadPicture(uri string) ([]byte, string, error) {
+	if err := c.require(FeatureReadPicture); err != nil {
+		return nil, "", err
+	}
+	data, meta, err := c.readBinary("readpicture", uri)
+	if err != nil || data == nil {
+		return nil, "", err
+	}
+	return data, meta["type"], nil
+}
+
+// ACKError is an error response from MPD, such as
+// `ACK [50@0] {play} song doesn't exist: "10"`.
+type ACKError struct {
+	Code      int    // Error code, see the MPD protocol documentation
+	ListIndex int    // Position of the failed command within a command list
+	Command   string // Name of the failed command
+	Message   string
+}
+
+func (e *ACKError) Error() string {
+	return fmt.Sprintf("ACK [%d@%d] {%s} %s", e.Code, e.ListIndex, e.Command, e.Message)
+}
+
+// Errors matching ACKErrors with the corresponding MPD error code, e.g.
+// errors.Is(err, ErrNoExist) for a song or playlist that doesn't exist.
+var (
+	ErrNotList       = errors.New("mpd: not a command list")
+	ErrArg           = errors.New("mpd: bad argument")
+	ErrPassword      = errors.New("mpd: wrong password")
+	ErrPermission    = errors.New("mpd: permission denied")
+	ErrUnknown       = errors.New("mpd: unknown command")
+	ErrNoExist       = errors.New("mpd: no such object")
+	ErrPlaylistMax   = errors.New("mpd: playlist is full")
+	ErrSystem        = errors.New("mpd: system error")
+	ErrPlaylistLoad  = errors.New("mpd: could not load playlist")
+	ErrUpdateAlready = errors.New("mpd: database update already running")
+	ErrPlayerSync    = errors.New("mpd: player out of sync")
+	ErrExist         = errors.New("mpd: object already exists")
+)
+
+// ackCodes maps the sentinels above to the error codes of the protocol.
+var ackCodes = map[error]int{
+	ErrNotList:       1,
+	ErrArg:           2,
+	ErrPassword:      3,
+	ErrPermission:    4,
+	ErrUnknown:       5,
+	ErrNoExist:       50,
+	ErrPlaylistMax:   51,
+	ErrSystem:        52,
+	ErrPlaylistLoad:  53,
+	ErrUpdateAlready: 54,
+	ErrPlayerSync:    55,
+	ErrExist:         56,
+}
+
+// Is makes an ACKError match the sentinel for its code.
+func (e *ACKError) Is(target error) bool {
+	code, ok := ackCodes[target]
+	return ok && code == e.Code
+}
+
+// parseACK parses an "ACK [code@index] {command} message" line.
+func parseACK(line string) *ACKError {
+	rest := strings.TrimSpace(strings.TrimPrefix(line, "ACK"))
+	e := &ACKError{Message: rest}
+	if !strings.HasPrefix(rest, "[") {
+		return e
+	}
+	end := strings.Index(rest, "]")
+	if end < 0 {
+		return e
+	}
+	codes := strings.SplitN(rest[1:end], "@", 2)
+	if len(codes) == 2 {
+		e.Code, _ = strconv.Atoi(codes[0])
+		e.ListIndex, _ = strconv.Atoi(codes[1])
+	}
+	rest = strings.TrimSpace(rest[end+1:])
+	if strings.HasPrefix(rest, "{") {
+		if end := strings.Index(rest, "}"); end >= 0 {
+			e.Command = rest[1:end]
+			rest = strings.TrimSpace(rest[end+1:])
+		}
+	}
+	e.Message = rest
+	return e
+}
+
+// quoteArg quotes s as a single MPD command argument.
+func quoteArg(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
 }
 
 // parseKVP parses a list of "key: value" strings into a map.
@@ -121,27 +1003,45 @@ func parseKVP(lines []string) map[string]string {
 	return m
 }
 
+// parseValues returns the values of every line with the given key, in
+// order. MPD repeats tag lines for tags with several values.
+func parseValues(lines []string, key string) []string {
+	var values []string
+	prefix := key + ": "
+	for _, line := range lines {
+		if strings.HasPrefix(line, prefix) {
+			values = append(values, line[len(prefix):])
+		}
+	}
+	return values
+}
+
 // List sends a `list` command to MPD.
 // It returns a list of values for the given tag.
 // For example, `List("artist")` returns all artists.
 // `List("album", "artist", "Daft Punk")` returns albums by Daft Punk.
-func (c *Client) List(tag string, args ...string) ([]string, error) {
-	cmd := fmt.Sprintf("list %s", tag)
-	for i := 0; i < len(args); i += 2 {
-		cmd += fmt.Sprintf(" %s \"%s\"", args[i], args[i+1])
+func (c *Client) List(tag Tag, args ...string) ([]string, error) {
+	var criteria []Filter
+	for i := 0; i+1 < len(args); i += 2 {
+		criteria = append(criteria, Filter{Tag: Tag(args[i]), Value: args[i+1]})
 	}
+	return c.list(tag, criteria)
+}
 
-	lines, err := c.sendCommand(cmd)
+func (c *Client) list(tag Tag, criteria []Filter) ([]string, error) {
+	lines, err := c.sendCommand("list " + string(tag) + filterArgs(criteria))
 	if err != nil {
 		return nil, err
 	}
 
 	// The response is in "key: value" format, we just want the values.
+	// MPD answers with the canonical spelling of the tag, which may differ
+	// from the requested one in case.
 	values := make([]string, 0, len(lines))
-	prefix := tag + ": "
 	for _, line := range lines {
-		if strings.HasPrefix(strings.ToLower(line), prefix) {
-			values = append(values, line[len(prefix):])
+		parts := strings.SplitN(line, ": ", 2)
+		if len(parts) == 2 && strings.EqualFold(parts[0], string(tag)) {
+			values = append(values, parts[1])
 		}
 	}
 	return values, nil
@@ -149,19 +1049,103 @@ func (c *Client) List(tag string, args ...string) ([]string, error) {
 
 // Status fetches the current status from MPD and populates a Status struct.
 func (c *Client) Status() (*Status, error) {
-	lines, err := c.sendCommand("status")
+	return c.StatusContext(context.Background())
+}
+
+// StatusContext is like Status but gives up when ctx is done.
+// A command cut short closes the connection, see Client.
+func (c *Client) StatusContext(ctx context.Context) (*Status, error) {
+	s, err := c.status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// If a song is playing or paused, get its details. The metadata is
+	// reused from the previous call as long as neither the song, the
+	// player state nor the queue changed in between. Streams change their
+	// tags without any of those changing, so they are always fetched.
+	if s.State != StatePlay && s.State != StatePause {
+		c.setSongCache(songCache{})
+		return s, nil
+	}
+	song, ok := c.cachedSong(s)
+	if !ok {
+		currentSongLines, err := c.sendCommandContext(ctx, "currentsong")
+		if err != nil {
+			// Log the error but don't fail the whole status update
+			log.WithError(err).Warn("mpd: could not get current song")
+			c.setSongCache(songCache{})
+			return s, nil
+		}
+		parsed := parseSong(currentSongLines)
+		song = songCache{
+			valid:           true,
+			songID:          s.SongID,
+			state:           s.State,
+			playlistVersion: s.PlaylistVersion,
+			artist:          parsed.Artist,
+			album:           parsed.Album,
+			title:           songTitle(parseKVP(currentSongLines)),
+		}
+		c.setSongCache(song)
+	}
+	s.Artist = song.artist
+	s.Album = song.album
+	if song.title != "" {
+		s.Title = song.title
+	}
+
+	return s, nil
+}
+
+// cachedSong returns the cached metadata of the current song of s, if it
+// is still valid.
+func (c *Client) cachedSong(s *Status) (songCache, bool) {
+	c.songMu.Lock()
+	defer c.songMu.Unlock()
+	song := c.song
+	ok := song.valid && !s.IsStream && song.songID == s.SongID &&
+		song.state == s.State && song.playlistVersion == s.PlaylistVersion
+	return song, ok
+}
+
+// setSongCache replaces the cached song metadata.
+func (c *Client) setSongCache(song songCache) {
+	c.songMu.Lock()
+	c.song = song
+	c.songMu.Unlock()
+}
+
+// songTitle returns the Title tag in kv, or the Name tag that streams
+// carry instead, such as the name of a radio station.
+func songTitle(kv map[string]string) string {
+	if title := kv["Title"]; title != "" {
+		return title
+	}
+	return kv["Name"]
+}
+
+// status fetches the player status without the current song's metadata.
+func (c *Client) status(ctx context.Context) (*Status, error) {
+	lines, err := c.sendCommandContext(ctx, "status")
 	if err != nil {
 		return nil, err
 	}
 
 	kv := parseKVP(lines)
-	s := &Status{Volume: -1, SongID: -1, NextSongID: -1} // Defaults
+	s := &Status{Volume: -1, SongID: -1, NextSongID: -1, FetchedAt: time.Now()} // Defaults
+	if c.rawStatus {
+		s.Raw = kv
+	}
 
 	if state, ok := kv["state"]; ok {
-		s.State = state
+		s.State = State(state)
 	}
 	if volumeStr, ok := kv["volume"]; ok {
-		s.Volume, _ = strconv.Atoi(volumeStr)
+		if volume, err := strconv.Atoi(volumeStr); err == nil {
+			s.Volume = volume
+			s.VolumeAvailable = volume >= 0
+		}
 	}
 	if repeatStr, ok := kv["repeat"]; ok {
 		s.Repeat = (repeatStr == "1")
@@ -170,10 +1154,15 @@ func (c *Client) Status() (*Status, error) {
 		s.Random = (randomStr == "1")
 	}
 	if singleStr, ok := kv["single"]; ok {
-		s.Single = (singleStr == "1")
+		s.SingleOneshot = (singleStr == "oneshot")
+		s.Single = (singleStr == "1" || s.SingleOneshot)
 	}
 	if consumeStr, ok := kv["consume"]; ok {
-		s.Consume = (consumeStr == "1")
+		s.ConsumeOneshot = (consumeStr == "oneshot")
+		s.Consume = (consumeStr == "1" || s.ConsumeOneshot)
+	}
+	if playlistStr, ok := kv["playlist"]; ok {
+		s.PlaylistVersion, _ = strconv.Atoi(playlistStr)
 	}
 	if playlistLengthStr, ok := kv["playlistlength"]; ok {
 		s.PlaylistLength, _ = strconv.Atoi(playlistLengthStr)
@@ -203,53 +1192,257 @@ func (c *Client) Status() (*Status, error) {
 	if errorStr, ok := kv["error"]; ok {
 		s.Error = errorStr
 	}
-
-	// If a song is playing or paused, get its details
-	if s.State == "play" || s.State == "pause" {
-		currentSongLines, err := c.sendCommand("currentsong")
-		if err != nil {
-			// Log the error but don't fail the whole status update
-			log.Printf("mpd: could not get current song: %v", err)
-		} else {
-			songKV := parseKVP(currentSongLines)
-			s.Artist = songKV["Artist"]
-			s.Album = songKV["Album"]
-			s.Title = songKV["Title"]
-		}
-	}
+	s.Partition = kv["partition"]
+	// Not part of the status proper, but some servers report the title of
+	// a stream here. It is only a fallback for the current song's.
+	s.Title = songTitle(kv)
+	s.IsStream = (s.State == StatePlay || s.State == StatePause) && s.Duration == 0
 
 	return s, nil
 }
 
-// Play starts playback.
+// ErrEmptyQueue is returned by the playback commands when there is
+// nothing in the queue to play.
+var ErrEmptyQueue = errors.New("mpd: queue is empty")
+
+// ErrOutOfRange is returned by Play for a position past the end of the
+// queue.
+var ErrOutOfRange = errors.New("mpd: position out of range")
+
+// Play starts playback at the given queue position. A negative position
+// resumes or starts the current song.
 func (c *Client) Play(song int) error {
+	return c.PlayContext(context.Background(), song)
+}
+
+// PlayContext is like Play but gives up when ctx is done.
+// A command cut short closes the connection, see Client.
+func (c *Client) PlayContext(ctx context.Context, song int) error {
+	s, err := c.status(ctx)
+	if err != nil {
+		return err
+	}
+	if s.PlaylistLength == 0 {
+		return ErrEmptyQueue
+	}
+	if song >= s.PlaylistLength {
+		return ErrOutOfRange
+	}
+	return c.play(ctx, song)
+}
+
+// play sends the play command without checking the queue first.
+func (c *Client) play(ctx context.Context, song int) error {
 	cmd := "play"
 	if song >= 0 {
 		cmd = fmt.Sprintf("play %d", song)
 	}
-	_, err := c.sendCommand(cmd)
+	_, err := c.sendCommandContext(ctx, cmd)
 	return err
 }
 
 // PlayID plays the song with the given ID in the playlist.
 func (c *Client) PlayID(songID int) error {
+	return c.PlayIDContext(context.Background(), songID)
+}
+
+// PlayIDContext is like PlayID but gives up when ctx is done.
+// A command cut short closes the connection, see Client.
+func (c *Client) PlayIDContext(ctx context.Context, songID int) error {
 	cmd := "playid"
 	if songID >= 0 {
 		cmd = fmt.Sprintf("playid %d", songID)
 	}
-	_, err := c.sendCommand(cmd)
+	_, err := c.sendCommandContext(ctx, cmd)
 	return err
 }
 
 // Pause toggles the pause state.
 // Pass true to pause, false to unpause.
 func (c *Client) Pause(p bool) error {
+	return c.PauseContext(context.Background(), p)
+}
+
+// PauseContext is like Pause but gives up when ctx is done.
+// A command cut short closes the connection, see Client.
+func (c *Client) PauseContext(ctx context.Context, p bool) error {
 	pauseState := 0
 	if p {
 		pauseState = 1
 	}
 	cmd := fmt.Sprintf("pause %d", pauseState)
-	_, err := c.sendCommand(cmd)
+	_, err := c.sendCommandContext(ctx, cmd)
+	return err
+}
+
+// PlayPause toggles playback based on the current player state: it starts
+// playback when stopped, pauses when playing and resumes when paused.
+func (c *Client) PlayPause() error {
+	return c.PlayPauseContext(context.Background())
+}
+
+// PlayPauseContext is like PlayPause but gives up when ctx is done.
+// A command cut short closes the connection, see Client.
+func (c *Client) PlayPauseContext(ctx context.Context) error {
+	s, err := c.status(ctx)
+	if err != nil {
+		return err
+	}
+	cmd, err := PlayPauseCommand(s)
+	if err != nil {
+		return err
+	}
+	_, err = c.sendCommandContext(ctx, cmd)
+	return err
+}
+
+// PlayPauseCommand returns the MPD command that PlayPause sends in status
+// s: "pause 1" while playing, "pause 0" while paused and "play" while
+// stopped, since pausing a stopped player does nothing. It returns
+// ErrEmptyQueue if there is nothing to play. Backends that don't talk to
+// MPD through a Client use it to make a play/pause button behave the same.
+func PlayPauseCommand(s *Status) (string, error) {
+	switch s.State {
+	case StatePlay:
+		return "pause 1", nil
+	case StatePause:
+		return "pause 0", nil
+	default:
+		if s.PlaylistLength == 0 {
+			return "", ErrEmptyQueue
+		}
+		return "play", nil
+	}
+}
+
+// AddID adds the song with the given URI to the queue and returns its id.
+// The song is inserted at pos, or appended if pos is negative.
+func (c *Client) AddID(uri string, pos int) (int, error) {
+	cmd := fmt.Sprintf("addid %s", quoteArg(uri))
+	if pos >= 0 {
+		cmd = fmt.Sprintf("%s %d", cmd, pos)
+	}
+	lines, err := c.sendCommand(cmd)
+	if err != nil {
+		return 0, err
+	}
+	idStr, ok := parseKVP(lines)["Id"]
+	if !ok {
+		return 0, fmt.Errorf("mpd command '%s' returned no id", cmd)
+	}
+	return strconv.Atoi(idStr)
+}
+
+// PlayNext inserts the song with the given URI right after the current
+// song and returns its id. If nothing is playing, the song is inserted
+// at the front of the queue.
+func (c *Client) PlayNext(uri string) (int, error) {
+	s, err := c.status(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	pos := 0
+	if s.State == StatePlay || s.State == StatePause {
+		pos = s.Song + 1
+	}
+	return c.AddID(uri, pos)
+}
+
+// ErrEmptyLibrary is returned when an operation needs songs in the music
+// database but there are none.
+var ErrEmptyLibrary = errors.New("mpd: music library is empty")
+
+// PlayAllShuffled replaces the queue with the whole music library and
+// starts playing it in random order.
+func (c *Client) PlayAllShuffled() error {
+	lines, err := c.sendCommand("stats")
+	if err != nil {
+		return err
+	}
+	if songs, _ := strconv.Atoi(parseKVP(lines)["songs"]); songs == 0 {
+		return ErrEmptyLibrary
+	}
+	_, err = c.sendCommandList("clear", `add "/"`, "random 1", "play")
+	return err
+}
+
+// SeekCur seeks to the given position in seconds within the current song.
+func (c *Client) SeekCur(seconds float64) error {
+	return c.SeekCurContext(context.Background(), seconds)
+}
+
+// SeekCurContext is like SeekCur but gives up when ctx is done.
+// A command cut short closes the connection, see Client.
+func (c *Client) SeekCurContext(ctx context.Context, seconds float64) error {
+	_, err := c.sendCommandContext(ctx, fmt.Sprintf("seekcur %g", seconds))
+	return err
+}
+
+// SeekCurRelative seeks forward (positive delta) or backward (negative
+// delta) by delta seconds within the current song. The target is clamped
+// to the song, so a rewind near its start seeks to the start and a fast
+// forward near its end to its last second. Streams have no known duration
+// and are not clamped.
+func (c *Client) SeekCurRelative(delta float64) error {
+	return c.SeekCurRelativeContext(context.Background(), delta)
+}
+
+// SeekCurRelativeContext is like SeekCurRelative but gives up when ctx is done.
+// A command cut short closes the connection, see Client.
+func (c *Client) SeekCurRelativeContext(ctx context.Context, delta float64) error {
+	s, err := c.status(ctx)
+	if err != nil {
+		return err
+	}
+	if s.Duration <= 0 {
+		_, err = c.sendCommandContext(ctx, fmt.Sprintf("seekcur %+g", delta))
+		return err
+	}
+	target := s.Elapsed + delta
+	if end := float64(s.Duration - 1); target > end {
+		target = end
+	}
+	if target < 0 {
+		target = 0
+	}
+	return c.SeekCurContext(ctx, target)
+}
+
+// SeekPercent seeks to pct percent (0-100) of the current song. It fails
+// for streams and when nothing is playing, as there is no duration to
+// scale by.
+func (c *Client) SeekPercent(pct float64) error {
+	return c.SeekPercentContext(context.Background(), pct)
+}
+
+// SeekPercentContext is like SeekPercent but gives up when ctx is done.
+// A command cut short closes the connection, see Client.
+func (c *Client) SeekPercentContext(ctx context.Context, pct float64) error {
+	if pct < 0 || pct > 100 {
+		return fmt.Errorf("mpd: seek percentage %g out of range 0-100", pct)
+	}
+	s, err := c.status(ctx)
+	if err != nil {
+		return err
+	}
+	if s.Duration <= 0 {
+		return errors.New("mpd: current song has no known duration")
+	}
+	return c.SeekCurContext(ctx, pct/100*float64(s.Duration))
+}
+
+// SetVolume sets the volume to a value between 0 and 100.
+func (c *Client) SetVolume(volume int) error {
+	return c.SetVolumeContext(context.Background(), volume)
+}
+
+// SetVolumeContext is like SetVolume but gives up when ctx is done.
+// A command cut short closes the connection, see Client.
+func (c *Client) SetVolumeContext(ctx context.Context, volume int) error {
+	if volume < 0 || volume > 100 {
+		return fmt.Errorf("mpd: volume %d out of range 0-100", volume)
+	}
+	_, err := c.sendCommandContext(ctx, fmt.Sprintf("setvol %d", volume))
 	return err
 }
 
@@ -286,50 +1479,183 @@ func (c *Client) Single(s bool) error {
 	return err
 }
 
-// Next plays the next song in the playlist.
-func (c *Client) Next() error {
-	_, err := c.sendCommand("next")
+// SingleOneshot enables single mode until the current song ends.
+func (c *Client) SingleOneshot() error {
+	if err := c.require(FeatureSingleOneshot); err != nil {
+		return err
+	}
+	_, err := c.sendCommand("single oneshot")
 	return err
 }
 
-// Previous plays the previous song in the playlist.
-func (c *Client) Previous() error {
-	_, err := c.sendCommand("previous")
+// Consume enables or disables consume mode.
+func (c *Client) Consume(enable bool) error {
+	consumeState := 0
+	if enable {
+		consumeState = 1
+	}
+	cmd := fmt.Sprintf("consume %d", consumeState)
+	_, err := c.sendCommand(cmd)
 	return err
 }
 
-// WatchStatus connects to the MPD server at the given address and periodically
-// updates the public CurrentStatus variable. It handles reconnecting if the
-// connection is lost. This function is designed to be run in a goroutine.
-func WatchStatus(addr string, interval time.Duration) {
-	for {
-		client, err := NewClient(addr)
-		if err != nil {
-			log.Printf("mpd: failed to connect to %s: %v. Retrying in %s...", addr, err, interval)
-			time.Sleep(interval)
-			continue
+// ConsumeOneshot enables consume mode until the current song ends.
+func (c *Client) ConsumeOneshot() error {
+	if err := c.require(FeatureConsumeOneshot); err != nil {
+		return err
+	}
+	_, err := c.sendCommand("consume oneshot")
+	return err
+}
+
+// Mode is one of the boolean playback modes.
+type Mode int
+
+const (
+	ModeRepeat Mode = iota
+	ModeRandom
+	ModeSingle
+	ModeConsume
+)
+
+// String returns the MPD command name of the mode.
+func (m Mode) String() string {
+	switch m {
+	case ModeRepeat:
+		return "repeat"
+	case ModeRandom:
+		return "random"
+	case ModeSingle:
+		return "single"
+	case ModeConsume:
+		return "consume"
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// Toggle flips the given mode. MPD has no toggle command, so the current
+// value is read first; a change made by another client in between may be
+// overwritten. A mode in oneshot state counts as enabled and is turned off.
+func (c *Client) Toggle(mode Mode) error {
+	s, err := c.status(context.Background())
+	if err != nil {
+		return err
+	}
+	var enabled bool
+	switch mode {
+	case ModeRepeat:
+		enabled = s.Repeat
+	case ModeRandom:
+		enabled = s.Random
+	case ModeSingle:
+		enabled = s.Single
+	case ModeConsume:
+		enabled = s.Consume
+	default:
+		return fmt.Errorf("unknown mode %d", int(mode))
+	}
+	state := 1
+	if enabled {
+		state = 0
+	}
+	_, err = c.sendCommand(fmt.Sprintf("%s %d", mode, state))
+	return err
+}
+
+// Partition switches the client to the partition with the given name, so
+// that playback commands and Status apply to it. It fails with ErrNoExist
+// if there is no such partition.
+func (c *Client) Partition(name string) error {
+	_, err := c.sendCommand(fmt.Sprintf("partition %s", quoteArg(name)))
+	return err
+}
+
+// SetModes sets all four playback modes at once, e.g. to restore a saved
+// setup, in a single command list. MPD stops at the first mode it rejects,
+// leaving the following ones unchanged; the returned error then names that
+// mode and wraps the ACKError.
+func (c *Client) SetModes(repeat, random, single, consume bool) error {
+	modes := []Mode{ModeRepeat, ModeRandom, ModeSingle, ModeConsume}
+	values := []bool{repeat, random, single, consume}
+	cmds := make([]string, len(modes))
+	for i, mode := range modes {
+		state := 0
+		if values[i] {
+			state = 1
 		}
+		cmds[i] = fmt.Sprintf("%s %d", mode, state)
+	}
+	_, err := c.sendCommandList(cmds...)
+	var ack *ACKError
+	if errors.As(err, &ack) && ack.ListIndex >= 0 && ack.ListIndex < len(modes) {
+		return fmt.Errorf("mpd: could not set %s mode: %w", modes[ack.ListIndex], err)
+	}
+	return err
+}
 
-		log.Printf("mpd: connected to %s", addr)
+// Recover gets the player out of a stuck error state, e.g. after a read
+// error on a network mount, by clearing the error and restarting playback
+// of the current song in one command list.
+func (c *Client) Recover() error {
+	_, err := c.sendCommandList("clearerror", "stop", "play")
+	return err
+}
 
-		ticker := time.NewTicker(interval)
-		for range ticker.C {
-			status, err := client.Status()
-			if err != nil {
-				log.Printf("mpd: failed to get status: %v. Reconnecting...", err)
-				client.Close()
-				ticker.Stop()
-				break // Break inner loop to reconnect
-			}
+// Next plays the next song in the playlist. In repeat mode MPD goes from
+// the last song to the first one by itself. MPD refuses to skip while
+// stopped, so in that case playback is started at the next song instead.
+func (c *Client) Next() error {
+	return c.NextContext(context.Background())
+}
 
-			statusMutex.Lock()
-			CurrentStatus = status
-			statusMutex.Unlock()
+// NextContext is like Next but gives up when ctx is done.
+// A command cut short closes the connection, see Client.
+func (c *Client) NextContext(ctx context.Context) error {
+	s, err := c.status(ctx)
+	if err != nil {
+		return err
+	}
+	if s.PlaylistLength == 0 {
+		return ErrEmptyQueue
+	}
+	if s.State == StateStop {
+		pos := 0
+		if s.NextSongID >= 0 {
+			pos = s.NextSong
+		} else if s.SongID >= 0 && s.Song+1 < s.PlaylistLength {
+			pos = s.Song + 1
 		}
+		return c.play(ctx, pos)
+	}
+	_, err = c.sendCommandContext(ctx, "next")
+	return err
+}
 
-		// If the loop was broken, it means there was an error.
-		// The outer loop will handle reconnection after a delay.
-		// No need for an extra sleep here as the outer loop's `continue`
-		// will be followed by a sleep if the next connection attempt fails.
+// Previous plays the previous song in the playlist. In repeat mode MPD
+// goes from the first song to the last one by itself. MPD refuses to skip
+// while stopped, so in that case playback is started at the previous song
+// instead.
+func (c *Client) Previous() error {
+	return c.PreviousContext(context.Background())
+}
+
+// PreviousContext is like Previous but gives up when ctx is done.
+// A command cut short closes the connection, see Client.
+func (c *Client) PreviousContext(ctx context.Context) error {
+	s, err := c.status(ctx)
+	if err != nil {
+		return err
+	}
+	if s.PlaylistLength == 0 {
+		return ErrEmptyQueue
 	}
+	if s.State == StateStop {
+		pos := 0
+		if s.SongID >= 0 && s.Song > 0 {
+			pos = s.Song - 1
+		}
+		return c.play(ctx, pos)
+	}
+	_, err = c.sendCommandContext(ctx, "previous")
+	return err
 }
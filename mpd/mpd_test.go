@@ -0,0 +1,1093 @@
+package mpd_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leo82309/ipod/mpd"
+)
+
+// fakeServer is a minimal MPD server answering commands from a fixed table.
+// Commands without an entry are answered with a bare OK.
+type fakeServer struct {
+	ln        net.Listener
+	welcome   string
+	responses map[string]string
+
+	mu       sync.Mutex
+	commands []string
+}
+
+func newFakeServer(t *testing.T, responses map[string]string) *fakeServer {
+	t.Helper()
+	return newFakeServerVersion(t, "0.23.5", responses)
+}
+
+func newFakeServerVersion(t *testing.T, version string, responses map[string]string) *fakeServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	return startFakeServer(t, ln, version, responses)
+}
+
+func startFakeServer(t *testing.T, ln net.Listener, version string, responses map[string]string) *fakeServer {
+	s := &fakeServer{
+		ln:        ln,
+		welcome:   "OK MPD " + version,
+		responses: responses,
+	}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *fakeServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeServer) handle(conn net.Conn) {
+	defer conn.Close()
+	s.mu.Lock()
+	welcome := s.welcome
+	s.mu.Unlock()
+	fmt.Fprintf(conn, "%s\n", welcome)
+	r := bufio.NewReader(conn)
+	var list []string
+	inList, listOK := false, false
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimSpace(line)
+
+		switch cmd {
+		case "command_list_begin", "command_list_ok_begin":
+			inList, listOK, list = true, cmd == "command_list_ok_begin", nil
+			continue
+		case "command_list_end":
+			inList = false
+		default:
+			if inList {
+				list = append(list, cmd)
+				continue
+			}
+			list = []string{cmd}
+		}
+
+		var out strings.Builder
+		failed := false
+		for _, cmd := range list {
+			s.mu.Lock()
+			s.commands = append(s.commands, cmd)
+			resp := s.responses[cmd]
+			s.mu.Unlock()
+
+			if strings.HasPrefix(resp, "ACK") {
+				out.WriteString(resp)
+				failed = true
+				break
+			}
+			out.WriteString(resp)
+			if listOK {
+				out.WriteString("list_OK\n")
+			}
+		}
+		if !failed {
+			out.WriteString("OK\n")
+		}
+		listOK = false
+		fmt.Fprint(conn, out.String())
+	}
+}
+
+// Set changes the response to cmd.
+func (s *fakeServer) Set(cmd, resp string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[cmd] = resp
+}
+
+func (s *fakeServer) Addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *fakeServer) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+func newTestClient(t *testing.T, responses map[string]string) (*mpd.Client, *fakeServer) {
+	t.Helper()
+	srv := newFakeServer(t, responses)
+	c, err := mpd.NewClient(srv.Addr())
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, srv
+}
+
+func TestClient_Status(t *testing.T) {
+	c, _ := newTestClient(t, map[string]string{
+		"status": "volume: 80\n" +
+			"repeat: 1\n" +
+			"random: 0\n" +
+			"single: 0\n" +
+			"consume: 0\n" +
+			"playlist: 42\n" +
+			"playlistlength: 12\n" +
+			"state: play\n" +
+			"song: 3\n" +
+			"songid: 14\n" +
+			"nextsong: 4\n" +
+			"nextsongid: 15\n" +
+			"elapsed: 12.500\n" +
+			"duration: 215.000\n" +
+			"bitrate: 320\n",
+		"currentsong": "file: a/b.flac\n" +
+			"Artist: Daft Punk\n" +
+			"Album: Discovery\n" +
+			"Title: One More Time\n",
+	})
+
+	s, err := c.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if s.PlaylistVersion != 42 {
+		t.Errorf("PlaylistVersion = %d, want 42", s.PlaylistVersion)
+	}
+	if s.State != mpd.StatePlay || s.Volume != 80 || !s.Repeat || s.PlaylistLength != 12 {
+		t.Errorf("Status() = %+v", s)
+	}
+	if s.Song != 3 || s.SongID != 14 || s.NextSongID != 15 || s.Duration != 215 || s.Elapsed != 12.5 {
+		t.Errorf("Status() = %+v", s)
+	}
+	if s.Title != "One More Time" || s.Artist != "Daft Punk" || s.Album != "Discovery" {
+		t.Errorf("Status() song = %q/%q/%q", s.Artist, s.Album, s.Title)
+	}
+}
+
+func TestClient_PlayNext(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		want   string
+	}{
+		{"playing", "state: play\nsong: 3\nsongid: 14\n", `addid "x/y.mp3" 4`},
+		{"stopped", "state: stop\n", `addid "x/y.mp3" 0`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, srv := newTestClient(t, map[string]string{
+				"status": tt.status,
+				tt.want:  "Id: 21\n",
+			})
+			id, err := c.PlayNext("x/y.mp3")
+			if err != nil {
+				t.Fatalf("PlayNext() error = %v", err)
+			}
+			if id != 21 {
+				t.Errorf("PlayNext() = %d, want 21", id)
+			}
+			cmds := srv.Commands()
+			if got := cmds[len(cmds)-1]; got != tt.want {
+				t.Errorf("PlayNext() sent %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_PlayAllShuffled(t *testing.T) {
+	c, srv := newTestClient(t, map[string]string{
+		"stats": "artists: 3\nalbums: 4\nsongs: 40\n",
+	})
+	if err := c.PlayAllShuffled(); err != nil {
+		t.Fatalf("PlayAllShuffled() error = %v", err)
+	}
+	want := []string{"stats", "clear", `add "/"`, "random 1", "play"}
+	if got := srv.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("PlayAllShuffled() sent %q, want %q", got, want)
+	}
+
+	c, _ = newTestClient(t, map[string]string{
+		"stats": "artists: 0\nalbums: 0\nsongs: 0\n",
+	})
+	if err := c.PlayAllShuffled(); err != mpd.ErrEmptyLibrary {
+		t.Errorf("PlayAllShuffled() error = %v, want %v", err, mpd.ErrEmptyLibrary)
+	}
+}
+
+func TestClient_ACKError(t *testing.T) {
+	c, _ := newTestClient(t, map[string]string{
+		"status":  "state: stop\nplaylistlength: 20\n",
+		"play 10": "ACK [2@0] {play} Bad song index\n",
+	})
+	err := c.Play(10)
+	var ack *mpd.ACKError
+	if !errors.As(err, &ack) {
+		t.Fatalf("Play() error = %v, want an ACKError", err)
+	}
+	want := mpd.ACKError{Code: 2, ListIndex: 0, Command: "play", Message: "Bad song index"}
+	if *ack != want {
+		t.Errorf("Play() error = %+v, want %+v", *ack, want)
+	}
+}
+
+func TestClient_PlayOutOfRange(t *testing.T) {
+	c, srv := newTestClient(t, map[string]string{
+		"status": "state: stop\nplaylistlength: 3\n",
+	})
+	if err := c.Play(3); !errors.Is(err, mpd.ErrOutOfRange) {
+		t.Errorf("Play(3) error = %v, want ErrOutOfRange", err)
+	}
+	if err := c.Play(-1); err != nil {
+		t.Errorf("Play(-1) error = %v", err)
+	}
+	cmds := srv.Commands()
+	if got := cmds[len(cmds)-1]; got != "play" {
+		t.Errorf("Play(-1) sent %q, want %q", got, "play")
+	}
+}
+
+func TestClient_SetModes(t *testing.T) {
+	c, srv := newTestClient(t, map[string]string{})
+	if err := c.SetModes(true, false, true, false); err != nil {
+		t.Fatalf("SetModes() error = %v", err)
+	}
+	want := []string{"repeat 1", "random 0", "single 1", "consume 0"}
+	if got := srv.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("SetModes() sent %q, want %q", got, want)
+	}
+
+	srv.Set("single 0", "ACK [4@2] {single} you don't have permission for \"single\"\n")
+	err := c.SetModes(false, false, false, false)
+	if !errors.Is(err, mpd.ErrPermission) || !strings.Contains(err.Error(), "single mode") {
+		t.Errorf("SetModes() error = %v, want a permission error for single mode", err)
+	}
+}
+
+func TestClient_ACKErrorIs(t *testing.T) {
+	c, _ := newTestClient(t, map[string]string{
+		"playid 42": "ACK [50@0] {playid} No such song\n",
+	})
+	err := c.PlayID(42)
+	if !errors.Is(err, mpd.ErrNoExist) {
+		t.Errorf("PlayID() error = %v, want ErrNoExist", err)
+	}
+	if errors.Is(err, mpd.ErrArg) {
+		t.Errorf("PlayID() error = %v matches ErrArg", err)
+	}
+}
+
+func TestClient_ServerVersion(t *testing.T) {
+	c, _ := newTestClient(t, nil)
+	if got := c.ServerVersion(); got != "0.23.5" {
+		t.Errorf("ServerVersion() = %q, want %q", got, "0.23.5")
+	}
+	if v := c.Version(); !v.AtLeast(mpd.Version{Minor: 21}) || v.AtLeast(mpd.Version{Minor: 24}) {
+		t.Errorf("Version() = %v", v)
+	}
+}
+
+func TestClient_Supports(t *testing.T) {
+	srv := newFakeServerVersion(t, "0.21.11", nil)
+	c, err := mpd.NewClient(srv.Addr())
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer c.Close()
+
+	if !c.Supports(mpd.FeatureAlbumArt) {
+		t.Errorf("Supports(%v) = false, want true", mpd.FeatureAlbumArt)
+	}
+	if c.Supports(mpd.FeatureReadPicture) {
+		t.Errorf("Supports(%v) = true, want false", mpd.FeatureReadPicture)
+	}
+	if _, _, err := c.ReadPicture("a.flac"); !errors.Is(err, mpd.ErrUnsupported) {
+		t.Errorf("ReadPicture() error = %v, want %v", err, mpd.ErrUnsupported)
+	}
+	if got := srv.Commands(); len(got) != 0 {
+		t.Errorf("ReadPicture() sent %q, want nothing", got)
+	}
+}
+
+func TestClient_AlbumArt(t *testing.T) {
+	c, _ := newTestClient(t, map[string]string{
+		`albumart "a.flac" 0`: "size: 7\nbinary: 4\nabcd\n",
+		`albumart "a.flac" 4`: "size: 7\nbinary: 3\nefg\n",
+	})
+	data, err := c.AlbumArt("a.flac")
+	if err != nil {
+		t.Fatalf("AlbumArt() error = %v", err)
+	}
+	if string(data) != "abcdefg" {
+		t.Errorf("AlbumArt() = %q, want %q", data, "abcdefg")
+	}
+}
+
+func TestClient_AlbumArtTruncated(t *testing.T) {
+	c, _ := newTestClient(t, map[string]string{
+		`albumart "a.flac" 0`: "size: 7\nbinary: 4\nabcd\n",
+		`albumart "a.flac" 4`: "size: 7\nbinary: 0\n\n",
+	})
+	if data, err := c.AlbumArt("a.flac"); err == nil {
+		t.Errorf("AlbumArt() = %q, want an error", data)
+	}
+}
+
+func TestClient_AlbumArtLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		resp     string
+		wantConn bool
+	}{
+		// The declared size is checked before reading the chunk, so the
+		// connection stays usable.
+		{"declared size", "size: 100\nbinary: 4\nabcd\n", true},
+		// A chunk above the limit can't be skipped.
+		{"chunk", "size: 8\nbinary: 20\n" + strings.Repeat("x", 20) + "\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeServer(t, map[string]string{`albumart "a.flac" 0`: tt.resp})
+			c, err := mpd.NewClient(srv.Addr(), mpd.WithBinaryLimit(10))
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			defer c.Close()
+
+			if data, err := c.AlbumArt("a.flac"); err == nil {
+				t.Errorf("AlbumArt() = %q, want an error", data)
+			}
+			if got := c.IsConnected(); got != tt.wantConn {
+				t.Errorf("IsConnected() = %v, want %v", got, tt.wantConn)
+			}
+		})
+	}
+}
+
+func TestClient_NextPrevious(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		next   string
+		prev   string
+	}{
+		{"playing", "state: play\nsong: 3\nsongid: 14\nnextsong: 4\nnextsongid: 15\nplaylistlength: 6\n", "next", "previous"},
+		{"stopped", "state: stop\nsong: 3\nsongid: 14\nnextsong: 4\nnextsongid: 15\nplaylistlength: 6\n", "play 4", "play 2"},
+		{"stopped-no-song", "state: stop\nplaylistlength: 6\n", "play 0", "play 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, srv := newTestClient(t, map[string]string{"status": tt.status})
+			if err := c.Next(); err != nil {
+				t.Fatalf("Next() error = %v", err)
+			}
+			if err := c.Previous(); err != nil {
+				t.Fatalf("Previous() error = %v", err)
+			}
+			want := []string{"status", tt.next, "status", tt.prev}
+			if got := srv.Commands(); !reflect.DeepEqual(got, want) {
+				t.Errorf("sent %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestClient_Partition(t *testing.T) {
+	c, srv := newTestClient(t, map[string]string{
+		"status":            "state: play\npartition: kitchen\n",
+		`partition "attic"`: "ACK [50@0] {partition} partition does not exist\n",
+	})
+	if err := c.Partition("kitchen"); err != nil {
+		t.Fatalf("Partition() error = %v", err)
+	}
+	s, err := c.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if s.Partition != "kitchen" {
+		t.Errorf("Partition = %q, want %q", s.Partition, "kitchen")
+	}
+	if cmds := srv.Commands(); cmds[0] != `partition "kitchen"` {
+		t.Errorf("Partition() sent %q, want %q", cmds[0], `partition "kitchen"`)
+	}
+	if err := c.Partition("attic"); !errors.Is(err, mpd.ErrNoExist) {
+		t.Errorf("Partition(attic) error = %v, want ErrNoExist", err)
+	}
+}
+
+func TestClient_RawStatus(t *testing.T) {
+	srv := newFakeServer(t, map[string]string{
+		"status": "state: play\npartition: default\nlastloadedplaylist: mix\n",
+	})
+	c, err := mpd.NewClient(srv.Addr(), mpd.WithRawStatus())
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer c.Close()
+
+	s, err := c.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if got := s.Raw["lastloadedplaylist"]; got != "mix" {
+		t.Errorf("Raw[lastloadedplaylist] = %q, want %q", got, "mix")
+	}
+
+	c2, _ := newTestClient(t, map[string]string{"status": "state: play\n"})
+	s, err = c2.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if s.Raw != nil {
+		t.Errorf("Raw = %v without WithRawStatus, want nil", s.Raw)
+	}
+}
+
+func TestClient_StatusVolumeAvailable(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		volume int
+		want   bool
+	}{
+		{"present", "state: stop\nvolume: 0\n", 0, true},
+		{"disabled", "state: stop\nvolume: -1\n", -1, false},
+		{"missing", "state: stop\n", -1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestClient(t, map[string]string{"status": tt.status})
+			s, err := c.Status()
+			if err != nil {
+				t.Fatalf("Status() error = %v", err)
+			}
+			if s.Volume != tt.volume || s.VolumeAvailable != tt.want {
+				t.Errorf("Volume = %d, VolumeAvailable = %v, want %d, %v", s.Volume, s.VolumeAvailable, tt.volume, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_PlayPause(t *testing.T) {
+	tests := []struct {
+		state string
+		want  string
+	}{
+		{"play", "pause 1"},
+		{"pause", "pause 0"},
+		{"stop", "play"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.state, func(t *testing.T) {
+			c, srv := newTestClient(t, map[string]string{
+				"status": "state: " + tt.state + "\nplaylistlength: 3\n",
+			})
+			if err := c.PlayPause(); err != nil {
+				t.Fatalf("PlayPause() error = %v", err)
+			}
+			want := []string{"status", tt.want}
+			if got := srv.Commands(); !reflect.DeepEqual(got, want) {
+				t.Errorf("sent %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestClient_EmptyQueue(t *testing.T) {
+	c, srv := newTestClient(t, map[string]string{
+		"status": "state: stop\nplaylistlength: 0\n",
+	})
+	for name, fn := range map[string]func() error{
+		"Play":      func() error { return c.Play(-1) },
+		"Next":      c.Next,
+		"Previous":  c.Previous,
+		"PlayPause": c.PlayPause,
+	} {
+		if err := fn(); err != mpd.ErrEmptyQueue {
+			t.Errorf("%s() error = %v, want %v", name, err, mpd.ErrEmptyQueue)
+		}
+	}
+	for _, cmd := range srv.Commands() {
+		if cmd != "status" {
+			t.Errorf("sent %q, want only status", cmd)
+		}
+	}
+}
+
+func TestClient_StatusStream(t *testing.T) {
+	c, _ := newTestClient(t, map[string]string{
+		"status": "state: play\n" +
+			"song: 0\n" +
+			"songid: 1\n" +
+			"elapsed: 1234.567\n" +
+			"bitrate: 128\n" +
+			"audio: 44100:24:2\n",
+		"currentsong": "file: http://radio.example.com/stream\n" +
+			"Name: Example Radio\n" +
+			"Title: Some Song\n",
+	})
+
+	s, err := c.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if !s.IsStream {
+		t.Errorf("IsStream = false, want true")
+	}
+	if got := s.Progress(); got != 0 {
+		t.Errorf("Progress() = %v, want 0", got)
+	}
+	now := time.Now()
+	if got := s.ElapsedAt(now.Add(time.Second), now); got != s.Elapsed+1 {
+		t.Errorf("ElapsedAt() = %v, want %v", got, s.Elapsed+1)
+	}
+}
+
+func TestStatus_ElapsedAt(t *testing.T) {
+	fetched := time.Now()
+	tests := []struct {
+		name  string
+		state mpd.State
+		after time.Duration
+		want  float64
+	}{
+		{"playing", mpd.StatePlay, 2 * time.Second, 192},
+		{"near the end", mpd.StatePlay, 30 * time.Second, 200},
+		{"paused", mpd.StatePause, 30 * time.Second, 190},
+		{"stopped", mpd.StateStop, 30 * time.Second, 190},
+		{"clock went back", mpd.StatePlay, -time.Second, 190},
+	}
+	for _, tt := range tests {
+		s := &mpd.Status{State: tt.state, Elapsed: 190, Duration: 200}
+		if got := s.ElapsedAt(fetched.Add(tt.after), fetched); got != tt.want {
+			t.Errorf("%s: ElapsedAt() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClient_StatusSongCache(t *testing.T) {
+	c, srv := newTestClient(t, map[string]string{
+		"status":      "state: play\nsongid: 1\nplaylist: 5\nduration: 200.000\n",
+		"currentsong": "file: a.flac\nTitle: A\n",
+	})
+	fetches := func() int {
+		n := 0
+		for _, cmd := range srv.Commands() {
+			if cmd == "currentsong" {
+				n++
+			}
+		}
+		return n
+	}
+
+	steps := []struct {
+		name   string
+		status string
+		want   int
+	}{
+		{"first", "", 1},
+		{"unchanged", "", 1},
+		{"other song", "state: play\nsongid: 2\nplaylist: 5\nduration: 200.000\n", 2},
+		{"paused", "state: pause\nsongid: 2\nplaylist: 5\nduration: 200.000\n", 3},
+		{"retagged", "state: pause\nsongid: 2\nplaylist: 6\nduration: 200.000\n", 4},
+		{"stream", "state: play\nsongid: 3\nplaylist: 6\n", 5},
+		{"stream again", "", 6},
+	}
+	for _, step := range steps {
+		if step.status != "" {
+			srv.Set("status", step.status)
+		}
+		if _, err := c.Status(); err != nil {
+			t.Fatalf("%s: Status() error = %v", step.name, err)
+		}
+		if got := fetches(); got != step.want {
+			t.Errorf("%s: currentsong sent %d times, want %d", step.name, got, step.want)
+		}
+	}
+
+	// Concurrent polls share the cache.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Status()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestClient_StatusStreamTitle(t *testing.T) {
+	const stream = "file: http://radio.example.com/stream\n"
+	tests := []struct {
+		name        string
+		status      string
+		currentsong string
+		want        string
+	}{
+		{"title", "", stream + "Name: Example Radio\nTitle: Some Song\n", "Some Song"},
+		{"name", "", stream + "Name: Example Radio\n", "Example Radio"},
+		{"status fallback", "Title: From Status\n", "ACK [5@0] {currentsong} unknown command\n", "From Status"},
+		{"currentsong first", "Title: From Status\n", stream + "Title: Some Song\n", "Some Song"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestClient(t, map[string]string{
+				"status":      "state: play\nsongid: 1\n" + tt.status,
+				"currentsong": tt.currentsong,
+			})
+			s, err := c.Status()
+			if err != nil {
+				t.Fatalf("Status() error = %v", err)
+			}
+			if s.Title != tt.want {
+				t.Errorf("Title = %q, want %q", s.Title, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatus_Progress(t *testing.T) {
+	s := &mpd.Status{State: mpd.StatePlay, Elapsed: 50, Duration: 200}
+	if got := s.Progress(); got != 0.25 {
+		t.Errorf("Progress() = %v, want 0.25", got)
+	}
+}
+
+func TestClient_StatusOneshot(t *testing.T) {
+	c, _ := newTestClient(t, map[string]string{
+		"status": "state: play\nsingle: oneshot\nconsume: oneshot\n",
+	})
+	s, err := c.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if !s.Single || !s.SingleOneshot || !s.Consume || !s.ConsumeOneshot {
+		t.Errorf("Status() = %+v, want single and consume oneshot", s)
+	}
+
+	// consume oneshot needs MPD 0.24, the fake server is 0.23.
+	if err := c.ConsumeOneshot(); !errors.Is(err, mpd.ErrUnsupported) {
+		t.Errorf("ConsumeOneshot() error = %v, want %v", err, mpd.ErrUnsupported)
+	}
+	if err := c.SingleOneshot(); err != nil {
+		t.Errorf("SingleOneshot() error = %v", err)
+	}
+}
+
+func TestClient_CommandTimeout(t *testing.T) {
+	c, err := mpd.NewClient(newSilentServer(t), mpd.WithCommandTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	start := time.Now()
+	_, err = c.Status()
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("Status() error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Status() took %v, want about 50ms", elapsed)
+	}
+}
+
+func TestClient_Toggle(t *testing.T) {
+	tests := []struct {
+		mode   mpd.Mode
+		status string
+		want   string
+	}{
+		{mpd.ModeRepeat, "repeat: 0\n", "repeat 1"},
+		{mpd.ModeRepeat, "repeat: 1\n", "repeat 0"},
+		{mpd.ModeRandom, "random: 0\n", "random 1"},
+		{mpd.ModeRandom, "random: 1\n", "random 0"},
+		{mpd.ModeSingle, "single: 0\n", "single 1"},
+		{mpd.ModeSingle, "single: oneshot\n", "single 0"},
+		{mpd.ModeConsume, "consume: 0\n", "consume 1"},
+		{mpd.ModeConsume, "consume: 1\n", "consume 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			c, srv := newTestClient(t, map[string]string{
+				"status": "state: stop\n" + tt.status,
+			})
+			if err := c.Toggle(tt.mode); err != nil {
+				t.Fatalf("Toggle(%v) error = %v", tt.mode, err)
+			}
+			cmds := srv.Commands()
+			if got := cmds[len(cmds)-1]; got != tt.want {
+				t.Errorf("Toggle(%v) sent %q, want %q", tt.mode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_Config(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mpd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ln, err := net.Listen("unix", filepath.Join(dir, "socket"))
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := startFakeServer(t, ln, "0.23.5", map[string]string{
+		"config": "music_directory: /srv/music\nplaylist_directory: /srv/playlists\n",
+	})
+	c, err := mpd.NewClient(srv.Addr())
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer c.Close()
+
+	got, err := c.MusicDirectory()
+	if err != nil || got != "/srv/music" {
+		t.Errorf("MusicDirectory() = %q, %v, want %q", got, err, "/srv/music")
+	}
+}
+
+func TestClient_ConfigNotLocal(t *testing.T) {
+	c, srv := newTestClient(t, nil)
+	if _, err := c.Config(); !errors.Is(err, mpd.ErrNotLocal) {
+		t.Errorf("Config() error = %v, want ErrNotLocal", err)
+	}
+	if cmds := srv.Commands(); len(cmds) != 0 {
+		t.Errorf("Config() sent %q over TCP", cmds)
+	}
+}
+
+func TestClient_SeekPercent(t *testing.T) {
+	c, srv := newTestClient(t, map[string]string{
+		"status": "state: play\nduration: 200.000\n",
+	})
+	if err := c.SeekPercent(25); err != nil {
+		t.Fatalf("SeekPercent() error = %v", err)
+	}
+	cmds := srv.Commands()
+	if got := cmds[len(cmds)-1]; got != "seekcur 50" {
+		t.Errorf("SeekPercent(25) sent %q, want %q", got, "seekcur 50")
+	}
+
+	if err := c.SeekPercent(120); err == nil {
+		t.Error("SeekPercent(120) succeeded, want an error")
+	}
+	srv.Set("status", "state: play\n")
+	if err := c.SeekPercent(50); err == nil {
+		t.Error("SeekPercent() on a stream succeeded, want an error")
+	}
+}
+
+// newSilentServer accepts one connection, sends the welcome message and then
+// never answers.
+func newSilentServer(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(conn, "OK MPD 0.23.5\n")
+		ioutil.ReadAll(conn)
+	}()
+	return ln.Addr().String()
+}
+
+func TestClient_ConnectionLost(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(conn, "OK MPD 0.23.5\n")
+		bufio.NewReader(conn).ReadString('\n')
+		// Hang up in the middle of the response.
+		fmt.Fprintf(conn, "volume: 50\nstate: pl")
+		conn.Close()
+	}()
+
+	c, err := mpd.NewClient(ln.Addr().String())
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer c.Close()
+
+	if _, err := c.Status(); !errors.Is(err, mpd.ErrConnectionLost) {
+		t.Errorf("Status() error = %v, want ErrConnectionLost", err)
+	}
+	if c.IsConnected() {
+		t.Error("IsConnected() = true after the server hung up")
+	}
+	if _, err := c.Status(); !errors.Is(err, mpd.ErrConnectionLost) {
+		t.Errorf("second Status() error = %v, want ErrConnectionLost", err)
+	}
+}
+
+func TestClient_StatusContext(t *testing.T) {
+	c, err := mpd.NewClient(newSilentServer(t), mpd.WithCommandTimeout(0))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.StatusContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("StatusContext() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestClient_PlayContextCanceled(t *testing.T) {
+	c, err := mpd.NewClient(newSilentServer(t), mpd.WithCommandTimeout(0))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if err := c.PlayContext(ctx, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("PlayContext() error = %v, want %v", err, context.Canceled)
+	}
+
+	// The response to play may still arrive, so the connection is dropped.
+	if c.IsConnected() {
+		t.Error("IsConnected() = true after a cancelled command")
+	}
+	if _, err := c.Status(); !errors.Is(err, mpd.ErrConnectionLost) {
+		t.Errorf("Status() error = %v, want ErrConnectionLost", err)
+	}
+}
+
+func TestClient_ContextReleased(t *testing.T) {
+	c, _ := newTestClient(t, map[string]string{"status": "state: stop\n"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := c.StatusContext(ctx); err != nil {
+		t.Fatalf("StatusContext() error = %v", err)
+	}
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	// Canceling a finished call must not affect later commands.
+	if _, err := c.Status(); err != nil {
+		t.Errorf("Status() after cancel error = %v", err)
+	}
+}
+
+func TestClient_ContextWaitingForLock(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(conn, "OK MPD 0.23.5\n")
+		r := bufio.NewReader(conn)
+		for {
+			if _, err := r.ReadString('\n'); err != nil {
+				return
+			}
+			// A slow server keeps the first command in flight while the
+			// second one waits for the client.
+			time.Sleep(150 * time.Millisecond)
+			fmt.Fprintf(conn, "state: stop\nOK\n")
+		}
+	}()
+
+	c, err := mpd.NewClient(ln.Addr().String())
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer c.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Status()
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.PauseContext(ctx, true); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("PauseContext() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Status() error = %v, want the other call's deadline not to affect it", err)
+	}
+	if !c.IsConnected() {
+		t.Error("IsConnected() = false, want the connection kept")
+	}
+}
+
+func TestClient_CloseDuringCommand(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(conn, "OK MPD 0.23.5\n")
+		bufio.NewReader(conn).ReadString('\n')
+		// Answer slowly so that Close is called during the command.
+		time.Sleep(100 * time.Millisecond)
+		fmt.Fprintf(conn, "state: stop\nOK\n")
+		ioutil.ReadAll(conn)
+	}()
+
+	c, err := mpd.NewClient(ln.Addr().String())
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Status()
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if err := c.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Status() in flight error = %v, want it to finish", err)
+	}
+	if _, err := c.Status(); !errors.Is(err, mpd.ErrClosed) {
+		t.Errorf("Status() after Close error = %v, want ErrClosed", err)
+	}
+}
+
+func TestClient_Recover(t *testing.T) {
+	c, srv := newTestClient(t, nil)
+	if err := c.Recover(); err != nil {
+		t.Fatalf("Recover() error = %v", err)
+	}
+	want := []string{"clearerror", "stop", "play"}
+	if got := srv.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Recover() sent %q, want %q", got, want)
+	}
+}
+
+func TestClient_IsConnected(t *testing.T) {
+	c, err := mpd.NewClient(newSilentServer(t), mpd.WithCommandTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	defer c.Close()
+	if !c.IsConnected() {
+		t.Error("IsConnected() = false after connecting")
+	}
+	c.Status()
+	if c.IsConnected() {
+		t.Error("IsConnected() = true after a timed out command")
+	}
+
+	c, _ = newTestClient(t, nil)
+	c.Close()
+	if c.IsConnected() {
+		t.Error("IsConnected() = true after Close")
+	}
+}
+
+func TestClient_SeekCurRelative(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		delta  float64
+		want   string
+	}{
+		{"middle", "state: play\nelapsed: 30.000\nduration: 200.000\n", 10, "seekcur 40"},
+		{"rewind at start", "state: play\nelapsed: 2.000\nduration: 200.000\n", -5, "seekcur 0"},
+		{"forward at end", "state: play\nelapsed: 197.000\nduration: 200.000\n", 10, "seekcur 199"},
+		{"stream", "state: play\nelapsed: 2.000\n", -5, "seekcur -5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, srv := newTestClient(t, map[string]string{"status": tt.status})
+			if err := c.SeekCurRelative(tt.delta); err != nil {
+				t.Fatalf("SeekCurRelative() error = %v", err)
+			}
+			cmds := srv.Commands()
+			if got := cmds[len(cmds)-1]; got != tt.want {
+				t.Errorf("SeekCurRelative(%g) sent %q, want %q", tt.delta, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewClient_NotMPD(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	srv.mu.Lock()
+	srv.welcome = "SSH-2.0-OpenSSH_8.9"
+	srv.mu.Unlock()
+	_, err := mpd.NewClient(srv.Addr())
+	if !errors.Is(err, mpd.ErrNotMPD) {
+		t.Fatalf("NewClient() error = %v, want ErrNotMPD", err)
+	}
+	if !strings.Contains(err.Error(), "SSH-2.0-OpenSSH_8.9") {
+		t.Errorf("NewClient() error = %v, want the welcome line in it", err)
+	}
+}
+
+func TestNewClient_InvalidAddr(t *testing.T) {
+	for _, addr := range []string{"", "localhost:", "a:b:c"} {
+		if _, err := mpd.NewClient(addr); !errors.Is(err, mpd.ErrInvalidAddr) {
+			t.Errorf("NewClient(%q) error = %v, want ErrInvalidAddr", addr, err)
+		}
+	}
+}
+
+func TestNewClient_DefaultPort(t *testing.T) {
+	c, err := mpd.NewClient("127.0.0.1", mpd.WithDialTimeout(100*time.Millisecond))
+	if err == nil {
+		// Something is listening on the default port.
+		c.Close()
+		return
+	}
+	if errors.Is(err, mpd.ErrInvalidAddr) || !strings.Contains(err.Error(), "127.0.0.1:6600") {
+		t.Errorf("NewClient() error = %v, want a dial error for 127.0.0.1:6600", err)
+	}
+}
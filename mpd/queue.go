@@ -0,0 +1,337 @@
+package mpd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Song holds the metadata of a song as reported by commands such as
+// currentsong and playlistinfo.
+type Song struct {
+	ID       int      `json:"id"` // Queue id, or -1 if the song is not in the queue
+	File     string   `json:"file"`
+	Artist   string   `json:"artist"`            // All artists, joined with ", "
+	Artists  []string `json:"artists,omitempty"` // One entry per Artist tag
+	Album    string   `json:"album"`
+	Title    string   `json:"title"`
+	Track    int      `json:"track,omitempty"` // Track number, 0 if unknown
+	Disc     int      `json:"disc,omitempty"`  // Disc number, 0 if unknown
+	Duration float64  `json:"duration"`        // Seconds, 0 if unknown
+}
+
+// Equal reports whether s and other are the same song, the same file at
+// the same queue id. Two nil songs are equal.
+func (s *Song) Equal(other *Song) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+	return s.ID == other.ID && s.File == other.File
+}
+
+// MetadataEqual reports whether s and other show the same title, artist
+// and album, e.g. to decide whether a display needs redrawing. It also
+// catches tag changes of a stream, which stays the same song. Two nil songs
+// are equal.
+func (s *Song) MetadataEqual(other *Song) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+	return s.Title == other.Title && s.Artist == other.Artist && s.Album == other.Album
+}
+
+// QueueItem is a song in the queue. Its JSON form is a flat object with
+// the song fields and its position.
+type QueueItem struct {
+	Song
+	Pos int `json:"pos"` // Position in the queue
+}
+
+// Queue is the list of songs in the queue, in playing order.
+type Queue []QueueItem
+
+// IndexByID returns the index of the item with the given song id, or -1.
+func (q Queue) IndexByID(id int) int {
+	for i := range q {
+		if q[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// CurrentIndex returns the index of the current song of s, or -1 if there
+// is none.
+func (q Queue) CurrentIndex(s *Status) int {
+	if s == nil || s.SongID < 0 {
+		return -1
+	}
+	return q.IndexByID(s.SongID)
+}
+
+// Next returns the item that plays after the current song of s, or nil at
+// the end of the queue.
+func (q Queue) Next(s *Status) *QueueItem {
+	if s == nil || s.NextSongID < 0 {
+		return nil
+	}
+	if i := q.IndexByID(s.NextSongID); i >= 0 {
+		return &q[i]
+	}
+	return nil
+}
+
+// Duration returns the total duration of the queue in seconds.
+func (q Queue) Duration() float64 {
+	var d float64
+	for i := range q {
+		d += q[i].Duration
+	}
+	return d
+}
+
+// Find returns the first item for which match returns true, or nil.
+func (q Queue) Find(match func(*QueueItem) bool) *QueueItem {
+	for i := range q {
+		if match(&q[i]) {
+			return &q[i]
+		}
+	}
+	return nil
+}
+
+// PlaylistInfo returns the songs in the queue.
+func (c *Client) PlaylistInfo() (Queue, error) {
+	var q Queue
+	err := c.PlaylistInfoFunc(func(item QueueItem) error {
+		q = append(q, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// PlaylistInfoFunc calls fn for every song in the queue as it is received,
+// without holding the whole queue in memory. If fn returns an error,
+// listing stops and that error is returned. fn runs while c is busy with
+// the listing, so it must not use c: any call would block forever.
+func (c *Client) PlaylistInfoFunc(fn func(QueueItem) error) error {
+	isStart := func(line string) bool {
+		return strings.HasPrefix(line, "file: ")
+	}
+	return c.sendRecordsFunc("playlistinfo", isStart, func(record []string) error {
+		return fn(parseQueueItem(record))
+	})
+}
+
+// PlaylistID returns the queue item with the given song id.
+func (c *Client) PlaylistID(id int) (*QueueItem, error) {
+	lines, err := c.sendCommand(fmt.Sprintf("playlistid %d", id))
+	if err != nil {
+		return nil, err
+	}
+	item := parseQueueItem(lines)
+	return &item, nil
+}
+
+// rangeArg formats the positions start (inclusive) to end (exclusive) as a
+// START:END argument.
+func rangeArg(start, end int) (string, error) {
+	if start < 0 || end < start {
+		return "", fmt.Errorf("mpd: invalid range %d:%d", start, end)
+	}
+	return fmt.Sprintf("%d:%d", start, end), nil
+}
+
+// DeleteRange removes the songs at positions start (inclusive) to end
+// (exclusive) from the queue.
+func (c *Client) DeleteRange(start, end int) error {
+	r, err := rangeArg(start, end)
+	if err != nil {
+		return err
+	}
+	_, err = c.sendCommand("delete " + r)
+	return err
+}
+
+// MoveRange moves the songs at positions start (inclusive) to end
+// (exclusive) so that the first of them ends up at position to.
+func (c *Client) MoveRange(start, end, to int) error {
+	r, err := rangeArg(start, end)
+	if err != nil {
+		return err
+	}
+	_, err = c.sendCommand(fmt.Sprintf("move %s %d", r, to))
+	return err
+}
+
+// PlaylistDeleteRange removes the songs at positions start (inclusive) to
+// end (exclusive) from the stored playlist name.
+func (c *Client) PlaylistDeleteRange(name string, start, end int) error {
+	r, err := rangeArg(start, end)
+	if err != nil {
+		return err
+	}
+	_, err = c.sendCommand(fmt.Sprintf("playlistdelete %s %s", quoteArg(name), r))
+	return err
+}
+
+// CurrentQueueItem returns the queue item that is playing or paused, or
+// nil if playback is stopped.
+func (c *Client) CurrentQueueItem() (*QueueItem, error) {
+	s, err := c.status(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if (s.State != StatePlay && s.State != StatePause) || s.SongID < 0 {
+		return nil, nil
+	}
+	return c.PlaylistID(s.SongID)
+}
+
+// NextSong returns the song that plays after the current one, or nil if
+// there is none, e.g. at the end of the queue without repeat.
+func (c *Client) NextSong() (*Song, error) {
+	s, err := c.status(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if s.NextSongID < 0 {
+		return nil, nil
+	}
+	item, err := c.PlaylistID(s.NextSongID)
+	if err != nil {
+		return nil, err
+	}
+	return &item.Song, nil
+}
+
+// MoveID moves the song with the given id to position to in the queue.
+func (c *Client) MoveID(id, to int) error {
+	_, err := c.sendCommand(fmt.Sprintf("moveid %d %d", id, to))
+	return err
+}
+
+// MoveToNext moves the song with the given id right after the current
+// song, so that it plays next. If nothing is playing, the song is moved to
+// the front of the queue.
+func (c *Client) MoveToNext(id int) error {
+	s, err := c.status(context.Background())
+	if err != nil {
+		return err
+	}
+	if s.State != StatePlay && s.State != StatePause {
+		return c.MoveID(id, 0)
+	}
+	if id == s.SongID {
+		return nil
+	}
+
+	item, err := c.PlaylistID(id)
+	if err != nil {
+		return err
+	}
+	// Taking out a song before the current one shifts the current song
+	// up by one position.
+	to := s.Song + 1
+	if item.Pos < s.Song {
+		to = s.Song
+	}
+	return c.MoveID(id, to)
+}
+
+// AddTagID sets tag to value on the queued song with the given id, e.g. to
+// fix the title of a stream. The tag is not written to the file.
+func (c *Client) AddTagID(id int, tag Tag, value string) error {
+	_, err := c.sendCommand(fmt.Sprintf("addtagid %d %s %s", id, tag, quoteArg(value)))
+	return err
+}
+
+// ClearTagID removes tag, or every tag if tag is empty, from the queued
+// song with the given id.
+func (c *Client) ClearTagID(id int, tag Tag) error {
+	cmd := fmt.Sprintf("cleartagid %d", id)
+	if tag != "" {
+		cmd += " " + string(tag)
+	}
+	_, err := c.sendCommand(cmd)
+	return err
+}
+
+// sendRecordsFunc sends a command whose response lists several entities
+// and calls fn with the lines of each entity as soon as it is complete.
+// isStart reports whether a line starts a new entity.
+func (c *Client) sendRecordsFunc(command string, isStart func(line string) bool, fn func(record []string) error) error {
+	var record []string
+	flush := func() error {
+		if record == nil {
+			return nil
+		}
+		r := record
+		record = nil
+		return fn(r)
+	}
+
+	err := c.sendCommandFunc(command, func(line string) error {
+		if isStart(line) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		record = append(record, line)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+// parseSong builds a Song from the lines of a song record.
+func parseSong(lines []string) Song {
+	kv := parseKVP(lines)
+	s := Song{
+		ID:      -1,
+		File:    kv["file"],
+		Artists: parseValues(lines, "Artist"),
+		Album:   kv["Album"],
+		Title:   kv["Title"],
+	}
+	s.Artist = strings.Join(s.Artists, ", ")
+	if idStr, ok := kv["Id"]; ok {
+		s.ID, _ = strconv.Atoi(idStr)
+	}
+	// Older servers only send the whole seconds in Time.
+	if durationStr, ok := kv["duration"]; ok {
+		s.Duration, _ = strconv.ParseFloat(durationStr, 64)
+	} else if timeStr, ok := kv["Time"]; ok {
+		seconds, _ := strconv.Atoi(timeStr)
+		s.Duration = float64(seconds)
+	}
+	s.Track = parseNumber(kv["Track"])
+	s.Disc = parseNumber(kv["Disc"])
+	return s
+}
+
+// parseNumber parses a track or disc number such as "3" or "3/12",
+// returning 0 if there is none.
+func parseNumber(v string) int {
+	if i := strings.IndexByte(v, '/'); i >= 0 {
+		v = v[:i]
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(v))
+	return n
+}
+
+// parseQueueItem builds a QueueItem from the lines of a song record.
+func parseQueueItem(lines []string) QueueItem {
+	item := QueueItem{Song: parseSong(lines), Pos: -1}
+	kv := parseKVP(lines)
+	if posStr, ok := kv["Pos"]; ok {
+		item.Pos, _ = strconv.Atoi(posStr)
+	}
+	return item
+}
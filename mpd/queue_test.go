@@ -0,0 +1,305 @@
+package mpd_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/leo82309/ipod/mpd"
+)
+
+func TestClient_PlaylistInfo(t *testing.T) {
+	c, _ := newTestClient(t, map[string]string{
+		"playlistinfo": "file: a.flac\n" +
+			"Artist: A\n" +
+			"Title: First\n" +
+			"duration: 100.5\n" +
+			"Pos: 0\n" +
+			"Id: 7\n" +
+			"file: b.flac\n" +
+			"Title: Second\n" +
+			"duration: 200\n" +
+			"Pos: 1\n" +
+			"Id: 9\n",
+	})
+
+	q, err := c.PlaylistInfo()
+	if err != nil {
+		t.Fatalf("PlaylistInfo() error = %v", err)
+	}
+	if len(q) != 2 {
+		t.Fatalf("PlaylistInfo() returned %d items, want 2", len(q))
+	}
+	if q[0].File != "a.flac" || q[0].Artist != "A" || q[0].ID != 7 || q[0].Pos != 0 {
+		t.Errorf("q[0] = %+v", q[0])
+	}
+	if q[1].Title != "Second" || q[1].ID != 9 || q[1].Pos != 1 {
+		t.Errorf("q[1] = %+v", q[1])
+	}
+}
+
+func TestClient_PlaylistInfoFunc(t *testing.T) {
+	c, _ := newTestClient(t, map[string]string{
+		"playlistinfo": "file: a.flac\nPos: 0\nId: 7\n" +
+			"file: b.flac\nPos: 1\nId: 9\n" +
+			"file: c.flac\nPos: 2\nId: 3\n",
+	})
+
+	var ids []int
+	errStop := errors.New("stop")
+	err := c.PlaylistInfoFunc(func(item mpd.QueueItem) error {
+		ids = append(ids, item.ID)
+		if item.Pos == 1 {
+			return errStop
+		}
+		return nil
+	})
+	if err != errStop {
+		t.Errorf("PlaylistInfoFunc() error = %v, want %v", err, errStop)
+	}
+	if want := []int{7, 9}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("PlaylistInfoFunc() yielded %v, want %v", ids, want)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	q := mpd.Queue{
+		{Song: mpd.Song{ID: 7, Title: "First", Duration: 100}, Pos: 0},
+		{Song: mpd.Song{ID: 9, Title: "Second", Duration: 200}, Pos: 1},
+		{Song: mpd.Song{ID: 3, Title: "Third", Duration: 50}, Pos: 2},
+	}
+	status := &mpd.Status{State: "play", SongID: 9, NextSongID: 3}
+
+	if got := q.IndexByID(3); got != 2 {
+		t.Errorf("IndexByID(3) = %d, want 2", got)
+	}
+	if got := q.IndexByID(42); got != -1 {
+		t.Errorf("IndexByID(42) = %d, want -1", got)
+	}
+	if got := q.CurrentIndex(status); got != 1 {
+		t.Errorf("CurrentIndex() = %d, want 1", got)
+	}
+	if got := q.Next(status); got == nil || got.ID != 3 {
+		t.Errorf("Next() = %+v, want id 3", got)
+	}
+	if got := q.Next(&mpd.Status{SongID: 3, NextSongID: -1}); got != nil {
+		t.Errorf("Next() at end = %+v, want nil", got)
+	}
+	if got := q.Duration(); got != 350 {
+		t.Errorf("Duration() = %v, want 350", got)
+	}
+	found := q.Find(func(item *mpd.QueueItem) bool { return item.Title == "First" })
+	if found == nil || found.ID != 7 {
+		t.Errorf("Find() = %+v, want id 7", found)
+	}
+}
+
+func TestQueueItem_MarshalJSON(t *testing.T) {
+	item := mpd.QueueItem{
+		Song: mpd.Song{ID: 7, File: "a.flac", Artist: "A", Album: "B", Title: "C", Duration: 12.5},
+		Pos:  3,
+	}
+	got, err := json.Marshal(item)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	want := `{"id":7,"file":"a.flac","artist":"A","album":"B","title":"C","duration":12.5,"pos":3}`
+	if string(got) != want {
+		t.Errorf("json.Marshal() = %s, want %s", got, want)
+	}
+}
+
+func TestClient_MoveToNext(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		id     int
+		want   string
+	}{
+		{"after current", "state: play\nsong: 2\nsongid: 12\n", 15, "moveid 15 3"},
+		{"before current", "state: play\nsong: 2\nsongid: 12\n", 10, "moveid 10 2"},
+		{"stopped", "state: stop\n", 15, "moveid 15 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, srv := newTestClient(t, map[string]string{
+				"status":        tt.status,
+				"playlistid 15": "file: b.flac\nPos: 5\nId: 15\n",
+				"playlistid 10": "file: a.flac\nPos: 0\nId: 10\n",
+			})
+			if err := c.MoveToNext(tt.id); err != nil {
+				t.Fatalf("MoveToNext() error = %v", err)
+			}
+			cmds := srv.Commands()
+			if got := cmds[len(cmds)-1]; got != tt.want {
+				t.Errorf("MoveToNext() sent %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_PlaylistIDMultipleArtists(t *testing.T) {
+	c, _ := newTestClient(t, map[string]string{
+		"playlistid 4": "file: a.flac\n" +
+			"Artist: Performer\n" +
+			"Artist: Composer\n" +
+			"Title: Duet\n" +
+			"Pos: 0\n" +
+			"Id: 4\n",
+	})
+
+	item, err := c.PlaylistID(4)
+	if err != nil {
+		t.Fatalf("PlaylistID() error = %v", err)
+	}
+	if want := []string{"Performer", "Composer"}; !reflect.DeepEqual(item.Artists, want) {
+		t.Errorf("Artists = %q, want %q", item.Artists, want)
+	}
+	if want := "Performer, Composer"; item.Artist != want {
+		t.Errorf("Artist = %q, want %q", item.Artist, want)
+	}
+}
+
+func TestClient_NextSong(t *testing.T) {
+	c, srv := newTestClient(t, map[string]string{
+		"status":       "state: play\nsong: 0\nsongid: 7\nnextsong: 1\nnextsongid: 9\n",
+		"playlistid 9": "file: b.flac\nTitle: Second\nPos: 1\nId: 9\n",
+	})
+
+	song, err := c.NextSong()
+	if err != nil {
+		t.Fatalf("NextSong() error = %v", err)
+	}
+	if song == nil || song.ID != 9 || song.Title != "Second" {
+		t.Errorf("NextSong() = %+v, want id 9", song)
+	}
+
+	srv.Set("status", "state: play\nsong: 1\nsongid: 9\n")
+	song, err = c.NextSong()
+	if err != nil || song != nil {
+		t.Errorf("NextSong() at end of queue = %+v, %v, want nil, nil", song, err)
+	}
+}
+
+func TestClient_Ranges(t *testing.T) {
+	c, srv := newTestClient(t, nil)
+	if err := c.DeleteRange(2, 5); err != nil {
+		t.Fatalf("DeleteRange() error = %v", err)
+	}
+	if err := c.MoveRange(0, 2, 7); err != nil {
+		t.Fatalf("MoveRange() error = %v", err)
+	}
+	if err := c.PlaylistDeleteRange("road trip", 1, 3); err != nil {
+		t.Fatalf("PlaylistDeleteRange() error = %v", err)
+	}
+	want := []string{"delete 2:5", "move 0:2 7", `playlistdelete "road trip" 1:3`}
+	if got := srv.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+
+	if err := c.DeleteRange(3, 2); err == nil {
+		t.Error("DeleteRange(3, 2) succeeded, want an error")
+	}
+	if err := c.MoveRange(-1, 2, 0); err == nil {
+		t.Error("MoveRange(-1, 2, 0) succeeded, want an error")
+	}
+	if got := srv.Commands(); len(got) != len(want) {
+		t.Errorf("invalid ranges sent %q", got[len(want):])
+	}
+}
+
+func TestClient_CurrentQueueItem(t *testing.T) {
+	c, srv := newTestClient(t, map[string]string{
+		"status":        "state: pause\nsong: 3\nsongid: 12\n",
+		"playlistid 12": "file: d.flac\nTitle: Fourth\nPos: 3\nId: 12\n",
+	})
+
+	item, err := c.CurrentQueueItem()
+	if err != nil {
+		t.Fatalf("CurrentQueueItem() error = %v", err)
+	}
+	if item == nil || item.ID != 12 || item.Pos != 3 {
+		t.Errorf("CurrentQueueItem() = %+v, want id 12 at 3", item)
+	}
+
+	srv.Set("status", "state: stop\nsong: 3\nsongid: 12\n")
+	item, err = c.CurrentQueueItem()
+	if err != nil || item != nil {
+		t.Errorf("CurrentQueueItem() when stopped = %+v, %v, want nil, nil", item, err)
+	}
+}
+
+func TestClient_TagID(t *testing.T) {
+	c, srv := newTestClient(t, map[string]string{
+		"cleartagid 99": "ACK [50@0] {cleartagid} No such song\n",
+	})
+	if err := c.AddTagID(4, "Title", `Live "Session"`); err != nil {
+		t.Fatalf("AddTagID() error = %v", err)
+	}
+	if err := c.ClearTagID(4, "Title"); err != nil {
+		t.Fatalf("ClearTagID() error = %v", err)
+	}
+	want := []string{`addtagid 4 Title "Live \"Session\""`, "cleartagid 4 Title"}
+	if got := srv.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+	if err := c.ClearTagID(99, ""); !errors.Is(err, mpd.ErrNoExist) {
+		t.Errorf("ClearTagID(99) error = %v, want ErrNoExist", err)
+	}
+}
+
+func TestSong_Equal(t *testing.T) {
+	a := &mpd.Song{ID: 1, File: "a.flac", Title: "A"}
+	retagged := &mpd.Song{ID: 1, File: "a.flac", Title: "A (Live)"}
+	other := &mpd.Song{ID: 2, File: "a.flac", Title: "A"}
+
+	tests := []struct {
+		name      string
+		s, other  *mpd.Song
+		equal     bool
+		metaEqual bool
+	}{
+		{"both nil", nil, nil, true, true},
+		{"nil and song", nil, a, false, false},
+		{"song and nil", a, nil, false, false},
+		{"same", a, &mpd.Song{ID: 1, File: "a.flac", Title: "A"}, true, true},
+		{"retagged", a, retagged, true, false},
+		{"other id", a, other, false, true},
+	}
+	for _, tt := range tests {
+		if got := tt.s.Equal(tt.other); got != tt.equal {
+			t.Errorf("%s: Equal() = %v, want %v", tt.name, got, tt.equal)
+		}
+		if got := tt.s.MetadataEqual(tt.other); got != tt.metaEqual {
+			t.Errorf("%s: MetadataEqual() = %v, want %v", tt.name, got, tt.metaEqual)
+		}
+	}
+}
+
+func TestClient_SongDuration(t *testing.T) {
+	tests := []struct {
+		name   string
+		record string
+		want   float64
+	}{
+		{"duration", "duration: 243.517\n", 243.517},
+		{"time", "Time: 243\n", 243},
+		{"both", "Time: 243\nduration: 243.517\n", 243.517},
+		{"none", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestClient(t, map[string]string{
+				"playlistid 1": "file: a.flac\n" + tt.record + "Pos: 0\nId: 1\n",
+			})
+			item, err := c.PlaylistID(1)
+			if err != nil {
+				t.Fatalf("PlaylistID() error = %v", err)
+			}
+			if item.Duration != tt.want {
+				t.Errorf("Duration = %v, want %v", item.Duration, tt.want)
+			}
+		})
+	}
+}
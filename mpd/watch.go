@@ -0,0 +1,279 @@
+package mpd
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Event is a notification sent by WatchStatusEvents. It is a *StatusEvent,
+// a *SongChangedEvent or an *ErrorEvent.
+type Event interface {
+	event()
+}
+
+// StatusEvent carries a freshly fetched status.
+type StatusEvent struct {
+	Status *Status
+}
+
+// SongChangedEvent is sent when the current song id changes. It follows the
+// StatusEvent of the status that revealed the change.
+type SongChangedEvent struct {
+	OldSongID int     // -1 if there was no song before
+	NewSongID int     // -1 if there is no song now
+	Status    *Status // Status carrying the new song's metadata
+}
+
+// ErrorEvent is the last event sent by Watch when it gives up connecting,
+// see WatchConfig.MaxReconnects.
+type ErrorEvent struct {
+	Err error
+}
+
+func (*StatusEvent) event()      {}
+func (*SongChangedEvent) event() {}
+func (*ErrorEvent) event()       {}
+
+// WatchConfig configures Watch.
+type WatchConfig struct {
+	Addr    string   // MPD server address
+	Options []Option // Options for connecting to the server
+
+	// PollInterval is the time between two status fetches.
+	PollInterval time.Duration
+	// ReconnectInterval is the time to wait after a failed connection
+	// attempt. It defaults to PollInterval.
+	ReconnectInterval time.Duration
+	// MaxReconnects is the number of connection attempts in a row that may
+	// fail before Watch gives up, sends an ErrorEvent and closes the
+	// channel. The count starts over after every successful connection.
+	// Zero means retrying forever.
+	MaxReconnects int
+	// StatusOnly skips fetching the current song's metadata to save
+	// traffic. Artist and Album of the sent statuses are empty, and Title is
+	// only set if the status itself carries one, as some streams do.
+	StatusOnly bool
+
+	// OnSongChange, if set, is called once for every change of the current
+	// song id, with the same event that is sent on the channel. It runs on
+	// the watching goroutine, so polling pauses until it returns.
+	OnSongChange func(*SongChangedEvent)
+	// OnError, if set, is called with every failed connection attempt or
+	// status fetch, before the watcher retries.
+	OnError func(error)
+	// OnReconnect, if set, is called when a connection is established again
+	// after the previous one was lost, e.g. to clear a stale display. It is
+	// not called for the first connection.
+	OnReconnect func()
+
+	// Reconnect, if set, makes Watch drop its connection and connect again
+	// right away whenever it receives, instead of waiting for the next poll
+	// or connection attempt to notice that the server went away.
+	Reconnect <-chan struct{}
+
+	// onConnect is called with every new connection.
+	onConnect func(*Client)
+}
+
+// WatchStatusEvents is like Watch with the default configuration for the
+// given address and poll interval.
+func WatchStatusEvents(ctx context.Context, addr string, interval time.Duration, opts ...Option) <-chan Event {
+	return Watch(ctx, WatchConfig{Addr: addr, Options: opts, PollInterval: interval})
+}
+
+// Watch connects to the MPD server and polls its status, reconnecting if
+// the connection is lost. Every status is sent on the returned channel as a
+// StatusEvent, followed by a SongChangedEvent whenever the song id differs
+// from the previous one. The channel is closed once ctx is done.
+func Watch(ctx context.Context, cfg WatchConfig) <-chan Event {
+	if cfg.ReconnectInterval <= 0 {
+		cfg.ReconnectInterval = cfg.PollInterval
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+
+		send := func(ev Event) bool {
+			select {
+			case events <- ev:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		lastSongID := -1
+		connected := false
+		failures := 0
+		for {
+			client, err := NewClient(cfg.Addr, cfg.Options...)
+			if err != nil {
+				if cfg.OnError != nil {
+					cfg.OnError(err)
+				}
+				failures++
+				if cfg.MaxReconnects > 0 && failures >= cfg.MaxReconnects {
+					log.WithError(err).Errorf("mpd: failed to connect to %s %d times. Giving up", cfg.Addr, failures)
+					send(&ErrorEvent{Err: fmt.Errorf("mpd: giving up after %d failed connection attempts: %w", failures, err)})
+					return
+				}
+				log.WithError(err).Warnf("mpd: failed to connect to %s. Retrying in %s...", cfg.Addr, cfg.ReconnectInterval)
+				select {
+				case <-time.After(cfg.ReconnectInterval):
+					continue
+				case <-cfg.Reconnect:
+					continue
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			log.Infof("mpd: connected to %s", cfg.Addr)
+			failures = 0
+			if cfg.onConnect != nil {
+				cfg.onConnect(client)
+			}
+			if connected && cfg.OnReconnect != nil {
+				cfg.OnReconnect()
+			}
+			connected = true
+
+			ok := pollStatus(ctx, client, cfg, func(status *Status) bool {
+				if !send(&StatusEvent{Status: status}) {
+					return false
+				}
+				if status.SongID != lastSongID {
+					ev := &SongChangedEvent{OldSongID: lastSongID, NewSongID: status.SongID, Status: status}
+					lastSongID = status.SongID
+					if cfg.OnSongChange != nil {
+						cfg.OnSongChange(ev)
+					}
+					return send(ev)
+				}
+				return true
+			})
+			client.Close()
+			if !ok {
+				return
+			}
+			// The connection was lost; reconnect right away. If that fails
+			// the loop above waits before the next attempt.
+		}
+	}()
+	return events
+}
+
+// pollStatus fetches the status from client every cfg.PollInterval and
+// passes it to fn until fn returns false, ctx is done or a fetch fails.
+// It returns false if polling should stop for good.
+func pollStatus(ctx context.Context, client *Client, cfg WatchConfig, fn func(*Status) bool) bool {
+	ticker := time.NewTicker(cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-cfg.Reconnect:
+			log.Info("mpd: reconnect requested")
+			return true
+		case <-ctx.Done():
+			return false
+		}
+
+		var status *Status
+		var err error
+		if cfg.StatusOnly {
+			status, err = client.status(ctx)
+		} else {
+			status, err = client.StatusContext(ctx)
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		if err != nil {
+			if cfg.OnError != nil {
+				cfg.OnError(err)
+			}
+			log.WithError(err).Warn("mpd: failed to get status. Reconnecting...")
+			return true
+		}
+		if !fn(status) {
+			return false
+		}
+	}
+}
+
+// WatchStatus connects to the MPD server at the given address and periodically
+// updates the public CurrentStatus variable. It handles reconnecting if the
+// connection is lost. This function is designed to be run in a goroutine.
+func WatchStatus(addr string, interval time.Duration, opts ...Option) {
+	WatchStatusConfig(WatchConfig{Addr: addr, Options: opts, PollInterval: interval})
+}
+
+// WatchStatusConfig is like WatchStatus with the settings of cfg, e.g. to
+// get notified of song changes with cfg.OnSongChange. It returns once
+// cfg.MaxReconnects is exceeded, if set.
+func WatchStatusConfig(cfg WatchConfig) {
+	cfg.Reconnect = reconnectRequests
+	cfg.onConnect = func(c *Client) {
+		statusMutex.Lock()
+		watchClient = c
+		statusMutex.Unlock()
+	}
+	for ev := range Watch(context.Background(), cfg) {
+		if ev, ok := ev.(*StatusEvent); ok {
+			statusMutex.Lock()
+			CurrentStatus = ev.Status
+			statusMutex.Unlock()
+		}
+	}
+}
+
+// reconnectRequests carries the requests of RequestReconnect to WatchStatus.
+var reconnectRequests = make(chan struct{}, 1)
+
+// RequestReconnect makes WatchStatus reconnect right away, e.g. after a
+// command on another connection failed because MPD was restarted. It does
+// not block, and requests made while one is pending are merged.
+func RequestReconnect() {
+	select {
+	case reconnectRequests <- struct{}{}:
+	default:
+	}
+}
+
+// Health is a snapshot of the MPD backend for liveness checks, e.g. to be
+// exposed over HTTP. It is filled in by WatchStatus.
+type Health struct {
+	Connected  bool          `json:"connected"`   // WatchStatus has a working connection
+	LastStatus time.Time     `json:"last_status"` // When CurrentStatus was fetched, zero if never
+	StatusAge  time.Duration `json:"status_age"`  // Time since LastStatus, zero if never
+}
+
+// watchClient is the current connection of WatchStatus.
+var watchClient *Client
+
+// LatestStatus returns CurrentStatus, or nil if WatchStatus hasn't fetched
+// one yet. Unlike reading CurrentStatus, it is safe while WatchStatus
+// updates it. The returned Status must not be modified.
+func LatestStatus() *Status {
+	statusMutex.RLock()
+	defer statusMutex.RUnlock()
+	return CurrentStatus
+}
+
+// CurrentHealth returns the health of the connection made by WatchStatus.
+func CurrentHealth() Health {
+	statusMutex.RLock()
+	defer statusMutex.RUnlock()
+	var h Health
+	if watchClient != nil {
+		h.Connected = watchClient.IsConnected()
+	}
+	if CurrentStatus != nil {
+		h.LastStatus = CurrentStatus.FetchedAt
+		h.StatusAge = time.Since(h.LastStatus)
+	}
+	return h
+}
@@ -0,0 +1,222 @@
+package mpd_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/leo82309/ipod/mpd"
+)
+
+// nextSongChange returns the next SongChangedEvent from events.
+func nextSongChange(t *testing.T, events <-chan mpd.Event) *mpd.SongChangedEvent {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatal("events channel closed")
+			}
+			if ev, ok := ev.(*mpd.SongChangedEvent); ok {
+				return ev
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for a SongChangedEvent")
+		}
+	}
+}
+
+func TestWatchStatusEvents_SongChanged(t *testing.T) {
+	srv := newFakeServer(t, map[string]string{
+		"status":      "state: play\nsongid: 14\n",
+		"currentsong": "file: a.flac\nTitle: A\n",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := mpd.WatchStatusEvents(ctx, srv.Addr(), 10*time.Millisecond)
+
+	ev := nextSongChange(t, events)
+	if ev.OldSongID != -1 || ev.NewSongID != 14 || ev.Status.Title != "A" {
+		t.Errorf("first change = %+v", ev)
+	}
+
+	srv.Set("status", "state: play\nsongid: 15\n")
+	srv.Set("currentsong", "file: b.flac\nTitle: B\n")
+	ev = nextSongChange(t, events)
+	if ev.OldSongID != 14 || ev.NewSongID != 15 || ev.Status.Title != "B" {
+		t.Errorf("second change = %+v", ev)
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestWatch_StatusOnly(t *testing.T) {
+	srv := newFakeServer(t, map[string]string{
+		"status":      "state: play\nsongid: 14\n",
+		"currentsong": "file: a.flac\nTitle: A\n",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := mpd.Watch(ctx, mpd.WatchConfig{
+		Addr:         srv.Addr(),
+		PollInterval: 10 * time.Millisecond,
+		StatusOnly:   true,
+	})
+
+	ev := nextSongChange(t, events)
+	if ev.NewSongID != 14 || ev.Status.Title != "" {
+		t.Errorf("change = %+v, want song 14 without metadata", ev)
+	}
+	for _, cmd := range srv.Commands() {
+		if cmd == "currentsong" {
+			t.Errorf("sent currentsong in status-only mode")
+		}
+	}
+}
+
+func TestWatch_OnSongChange(t *testing.T) {
+	srv := newFakeServer(t, map[string]string{
+		"status": "state: play\nsongid: 14\n",
+	})
+
+	changes := make(chan int, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := mpd.Watch(ctx, mpd.WatchConfig{
+		Addr:         srv.Addr(),
+		PollInterval: 5 * time.Millisecond,
+		StatusOnly:   true,
+		OnSongChange: func(ev *mpd.SongChangedEvent) { changes <- ev.NewSongID },
+	})
+
+	// Several polls of the same song must call the hook only once.
+	nextSongChange(t, events)
+	for i := 0; i < 5; i++ {
+		<-events
+	}
+	srv.Set("status", "state: play\nsongid: 15\n")
+	nextSongChange(t, events)
+
+	cancel()
+	for range events {
+	}
+	close(changes)
+	var got []int
+	for id := range changes {
+		got = append(got, id)
+	}
+	if len(got) != 2 || got[0] != 14 || got[1] != 15 {
+		t.Errorf("OnSongChange calls = %v, want [14 15]", got)
+	}
+}
+
+func TestWatch_OnErrorOnReconnect(t *testing.T) {
+	srv := newFakeServer(t, map[string]string{
+		"status": "ACK [5@0] {status} unknown command\n",
+	})
+
+	errs := make(chan error, 100)
+	reconnects := make(chan struct{}, 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := mpd.Watch(ctx, mpd.WatchConfig{
+		Addr:         srv.Addr(),
+		PollInterval: 5 * time.Millisecond,
+		StatusOnly:   true,
+		OnError:      func(err error) { errs <- err },
+		OnReconnect:  func() { reconnects <- struct{}{} },
+	})
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, mpd.ErrUnknown) {
+			t.Errorf("OnError(%v), want ErrUnknown", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnError was not called")
+	}
+	select {
+	case <-reconnects:
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnReconnect was not called")
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestWatch_MaxReconnects(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	attempts := 0
+	events := mpd.Watch(context.Background(), mpd.WatchConfig{
+		Addr:          addr,
+		PollInterval:  time.Millisecond,
+		MaxReconnects: 3,
+		OnError:       func(error) { attempts++ },
+	})
+
+	var last mpd.Event
+	timeout := time.After(2 * time.Second)
+	for done := false; !done; {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				done = true
+				break
+			}
+			last = ev
+		case <-timeout:
+			t.Fatal("Watch did not give up")
+		}
+	}
+	if _, ok := last.(*mpd.ErrorEvent); !ok {
+		t.Errorf("last event = %#v, want an ErrorEvent", last)
+	}
+	if attempts != 3 {
+		t.Errorf("made %d connection attempts, want 3", attempts)
+	}
+}
+
+func TestWatch_Reconnect(t *testing.T) {
+	srv := newFakeServer(t, map[string]string{"status": "state: stop\n"})
+
+	reconnect := make(chan struct{})
+	reconnected := make(chan struct{}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := mpd.Watch(ctx, mpd.WatchConfig{
+		Addr:         srv.Addr(),
+		PollInterval: time.Hour,
+		Reconnect:    reconnect,
+		OnReconnect:  func() { reconnected <- struct{}{} },
+	})
+
+	select {
+	case reconnect <- struct{}{}:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Watch did not take the reconnect request")
+	}
+	select {
+	case <-reconnected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Watch did not reconnect")
+	}
+
+	cancel()
+	for range events {
+	}
+}